@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// binaryPatterns generate the i-th byte of a body for body-with-null-bytes action.
+var binaryPatterns = map[string]func(i int) byte{
+	// only NUL bytes
+	"nul": func(int) byte { return 0 },
+	// all C0 control characters in a loop
+	"control": func(i int) byte { return byte(i % 0x20) },
+	// limeric text with every other byte replaced by NUL
+	"mixed": func(i int) byte {
+		if i%2 == 1 {
+			return 0
+		}
+		return limeric[i%len(limeric)]
+	},
+}
+
+// generatedChunkSize is how much of a generated body is kept in memory at once.
+const generatedChunkSize = 32 << 10
+
+// writeGenerated writes 'size' bytes, the i-th of them is gen(i), chunk by chunk,
+// so the body size isn't limited by memory.
+func writeGenerated(w io.Writer, size int, gen func(i int) byte) (int, error) {
+	chunk := make([]byte, min(size, generatedChunkSize))
+
+	written := 0
+	for written < size {
+		part := chunk[:min(size-written, len(chunk))]
+		for i := range part {
+			part[i] = gen(written + i)
+		}
+
+		n, err := w.Write(part)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// bodyWithNullBytes serves a body with embedded NUL and control bytes, pretending it's plain text.
+func bodyWithNullBytes(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	size, errSize := queryInt(query, "size", len(limeric))
+	if errSize != nil {
+		badRequest(rw, errSize)
+		return nil
+	}
+
+	pattern := query.Get("pattern")
+	if pattern == "" {
+		pattern = "mixed"
+	}
+
+	gen, ok := binaryPatterns[pattern]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown pattern %q, expected nul, control or mixed", pattern))
+		return nil
+	}
+
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Header().Set("Content-Length", strconv.Itoa(size))
+
+	n, errWrite := writeGenerated(rw, size, gen)
+	slog.InfoContext(ctx, "wrote binary body", "pattern", pattern, "bytes", n)
+	if errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
+
+type bomEncoding struct {
+	bom    []byte
+	encode func(s string) []byte
+}
+
+var bomEncodings = map[string]bomEncoding{
+	"utf-8": {
+		bom:    []byte{0xEF, 0xBB, 0xBF},
+		encode: func(s string) []byte { return []byte(s) },
+	},
+	"utf-16le": {
+		bom:    []byte{0xFF, 0xFE},
+		encode: func(s string) []byte { return encodeUTF16(s, binary.LittleEndian) },
+	},
+	"utf-16be": {
+		bom:    []byte{0xFE, 0xFF},
+		encode: func(s string) []byte { return encodeUTF16(s, binary.BigEndian) },
+	},
+}
+
+func encodeUTF16(s string, order binary.AppendByteOrder) []byte {
+	var data []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		data = order.AppendUint16(data, unit)
+	}
+
+	return data
+}
+
+// bodyWithBOM serves the limeric encoded as 'bom' (utf-8, utf-16le or utf-16be) with a byte-order mark.
+// The charset is declared in Content-Type unless 'charset=false' is passed.
+func bodyWithBOM(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	name := strings.ToLower(query.Get("bom"))
+	if name == "" {
+		name = "utf-8"
+	}
+
+	encoding, ok := bomEncodings[name]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown bom %q, expected utf-8, utf-16le or utf-16be", name))
+		return nil
+	}
+
+	withCharset, errCharset := queryBool(query, "charset", true)
+	if errCharset != nil {
+		badRequest(rw, errCharset)
+		return nil
+	}
+
+	body := append(append([]byte{}, encoding.bom...), encoding.encode(limeric)...)
+
+	contentType := "text/plain"
+	if withCharset {
+		contentType += "; charset=" + name
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	slog.InfoContext(ctx, "writing body with BOM", "bom", name, "content_type", contentType, "bytes", len(body))
+
+	if _, errWrite := rw.Write(body); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
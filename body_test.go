@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// limitedWriter fails once it's got more than 'limit' bytes.
+type limitedWriter struct {
+	limit int
+	bytes.Buffer
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, errors.New("limit exceeded")
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func TestWriteGenerated(t *testing.T) {
+	gen := binaryPatterns["control"]
+
+	for _, size := range []int{0, 1, generatedChunkSize, 3*generatedChunkSize + 7} {
+		buf := &bytes.Buffer{}
+		n, err := writeGenerated(buf, size, gen)
+		if err != nil || n != size || buf.Len() != size {
+			t.Fatalf("size %d: wrote %d bytes, buffered %d, error %v", size, n, buf.Len(), err)
+		}
+
+		for i, b := range buf.Bytes() {
+			if b != gen(i) {
+				t.Fatalf("size %d: byte %d is %#x, want %#x", size, i, b, gen(i))
+			}
+		}
+	}
+
+	// a huge body fails on write, without being allocated first
+	w := &limitedWriter{limit: 2 * generatedChunkSize}
+	n, err := writeGenerated(w, 1<<62, gen)
+	if err == nil || n != 2*generatedChunkSize {
+		t.Errorf("wrote %d bytes, error %v, want failure after %d bytes", n, err, 2*generatedChunkSize)
+	}
+}
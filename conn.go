@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// delayedCloseAfterResponse sends a complete keep-alive response,
+// then closes the connection after 'idle' delay without serving anything else,
+// unless the client closes it first.
+func delayedCloseAfterResponse(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	idle, errIdle := queryDuration(req.URL.Query(), "idle", 5*time.Second)
+	if errIdle != nil {
+		badRequest(rw, errIdle)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: ", strconv.Itoa(len(limeric)), "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Connection: keep-alive\r\n\r\n",
+		limeric,
+	)
+
+	if _, errWrite := w.Write(resp.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	responded := time.Now()
+	slog.InfoContext(ctx, "response sent, waiting before closing idle connection", "idle", idle)
+
+	waitCtx, cancel := context.WithTimeout(ctx, idle)
+	defer cancel()
+
+	waitClosed(waitCtx, w.Reader)
+
+	slog.InfoContext(ctx, "closing idle connection", "after_response", time.Since(responded),
+		"client_closed_first", !errors.Is(waitCtx.Err(), context.DeadlineExceeded))
+
+	return nil
+}
+
+// closeRequestSideOnly stops reading the request by closing the read side of the connection,
+// then sends a normal response after 'delay' and closes the connection.
+func closeRequestSideOnly(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	delay, errDelay := queryDuration(req.URL.Query(), "delay", time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	tcp, ok := tcpConn(conn)
+	if !ok {
+		return errors.New("half-closing connection: not a TCP connection")
+	}
+
+	if errClose := tcp.CloseRead(); errClose != nil {
+		return fmt.Errorf("half-closing connection: %w", errClose)
+	}
+
+	slog.InfoContext(ctx, "closed read side of connection", "delay", delay)
+
+	if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+		return errSleep
+	}
+
+	slog.InfoContext(ctx, "writing response after read side close")
+
+	if _, errWrite := w.Write(limericResponse(req)); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	slog.InfoContext(ctx, "closing connection")
+
+	return nil
+}
+
+type windowProbeReport struct {
+	Stall         string `json:"stall"`
+	BodyBytes     int64  `json:"body_bytes"`
+	FirstByteWait string `json:"first_byte_after_resume"`
+	CompleteWait  string `json:"complete_after_resume"`
+}
+
+// stallReadsForWindowProbe stops reading the request body for 'stall', so the receive window
+// closes and the client has to probe it, then reads the rest of the body and responds with timings as JSON.
+// Window probes are answered by the kernel, so only their effect is observable:
+// how soon the client resumes sending once the window reopens.
+func stallReadsForWindowProbe(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	stall, errStall := queryDuration(req.URL.Query(), "stall", 5*time.Second)
+	if errStall != nil {
+		badRequest(rw, errStall)
+		return nil
+	}
+
+	if req.ContentLength <= 0 {
+		badRequest(rw, errors.New("request body with Content-Length is required"))
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	// the server sends 100 Continue on the first body read, which never happens after hijacking
+	if strings.EqualFold(req.Header.Get("Expect"), "100-continue") {
+		if _, errWrite := w.WriteString("HTTP/1.1 100 Continue\r\n\r\n"); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+	}
+
+	stalled := time.Now()
+	slog.InfoContext(ctx, "stalling reads", "stall", stall, "content_length", req.ContentLength, "buffered", w.Reader.Buffered())
+
+	// ctx isn't canceled on hijacked connections, the stall is bounded anyway
+	if errSleep := sleepCtx(ctx, stall); errSleep != nil {
+		return errSleep
+	}
+
+	resumed := time.Now()
+	slog.InfoContext(ctx, "resuming reads", "stalled", resumed.Sub(stalled))
+
+	if _, errPeek := w.Reader.Peek(1); errPeek != nil {
+		return fmt.Errorf("reading request body: %w", errPeek)
+	}
+	firstByte := time.Since(resumed)
+
+	received, errRead := io.CopyN(io.Discard, w.Reader, req.ContentLength)
+	complete := time.Since(resumed)
+
+	slog.InfoContext(ctx, "request body read after stall",
+		"first_byte_after_resume", firstByte, "complete_after_resume", complete, "received", received)
+
+	if errRead != nil {
+		return fmt.Errorf("reading request body: %w", errRead)
+	}
+
+	body, errMarshal := json.Marshal(windowProbeReport{
+		Stall:         stall.String(),
+		BodyBytes:     received,
+		FirstByteWait: firstByte.String(),
+		CompleteWait:  complete.String(),
+	})
+	if errMarshal != nil {
+		return fmt.Errorf("encoding report: %w", errMarshal)
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: application/json\r\n",
+		"Content-Length: ", strconv.Itoa(len(body)), "\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	resp.Write(body)
+
+	if _, errWrite := w.Write(resp.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	return nil
+}
+
+// delayedEOF sends a complete response with Content-Length and Connection: close,
+// but keeps the connection open for 'delay' before closing it.
+func delayedEOF(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	delay, errDelay := queryDuration(req.URL.Query(), "delay", 10*time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: ", strconv.Itoa(len(limeric)), "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Connection: close\r\n\r\n",
+		limeric,
+	)
+
+	if _, errWrite := w.Write(resp.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	responded := time.Now()
+	slog.InfoContext(ctx, "response sent, delaying EOF", "delay", delay)
+
+	waitCtx, cancel := context.WithTimeout(ctx, delay)
+	defer cancel()
+
+	waitClosed(waitCtx, w.Reader)
+
+	slog.InfoContext(ctx, "closing connection", "after_response", time.Since(responded),
+		"client_closed_first", !errors.Is(waitCtx.Err(), context.DeadlineExceeded))
+
+	return nil
+}
+
+// tarpit holds the connection open, sending a body byte each 'interval' and never completing
+// the response. It spends two goroutines, one of them watching for the client to close, and a timer
+// per connection, nothing else.
+func (srv *service) tarpit(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	interval, errInterval := queryDuration(req.URL.Query(), "interval", time.Minute)
+	if errInterval == nil && interval == 0 {
+		errInterval = errors.New("interval must be positive")
+	}
+	if errInterval != nil {
+		badRequest(rw, errInterval)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	// the client leaves the tarpit as soon as it closes the connection
+	ctx, cancel := closeAware(ctx, w.Reader)
+	defer cancel()
+
+	entered := time.Now()
+	slog.InfoContext(ctx, "entering tarpit", "interval", interval, "tarpitted", srv.tarpitted.Add(1))
+	defer func() {
+		slog.InfoContext(ctx, "leaving tarpit", "held", time.Since(entered), "tarpitted", srv.tarpitted.Add(-1))
+	}()
+
+	// no Content-Length, the body ends only when the connection is closed
+	if _, errWrite := w.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nConnection: close\r\n\r\n"); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, errWrite := conn.Write([]byte{'.'}); errWrite != nil {
+			slog.DebugContext(ctx, "tarpit write failed", "error", errWrite)
+			return nil
+		}
+	}
+}
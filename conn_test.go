@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTarpitClientClose(t *testing.T) {
+	srv := &service{}
+	ts := newTestServer(t, srv)
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET /?action=tarpit HTTP/1.1\r\nHost: badserv\r\n\r\n")); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	waitFor(t, "client to enter tarpit", func() bool { return srv.tarpitted.Load() == 1 })
+
+	conn.Close()
+
+	// the default interval is a minute, the count must not wait for a failed write
+	waitFor(t, "client to leave tarpit", func() bool { return srv.tarpitted.Load() == 0 })
+}
+
+// waitFor polls cond until it holds, failing the test after 5 seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDelayedCloseAfterResponseClientCloses(t *testing.T) {
+	logs := captureLogs(t)
+	ts := newTestServer(t, &service{})
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET /?action=delayed-close-after-response&idle=1m HTTP/1.1\r\nHost: badserv\r\n\r\n")); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	conn.Close()
+
+	waitFor(t, "idle connection to close", func() bool {
+		return strings.Contains(logs.String(), `msg="closing idle connection"`)
+	})
+	if !strings.Contains(logs.String(), "client_closed_first=true") {
+		t.Errorf("client close isn't reported in:\n%s", logs)
+	}
+}
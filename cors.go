@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// slowPreflight answers CORS preflight OPTIONS requests after 'delay'.
+// Access-Control-* values come as is from 'allow-origin', 'allow-methods',
+// 'allow-headers' and 'max-age' parameters, so they may be restrictive or invalid on purpose.
+// Other requests get the limeric with Access-Control-Allow-Origin.
+func slowPreflight(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	param := func(name, def string) string {
+		if query.Has(name) {
+			return query.Get(name)
+		}
+		return def
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		origin = "*"
+	}
+
+	allowOrigin := param("allow-origin", origin)
+	allowMethods := param("allow-methods", "GET, POST, PUT, PATCH, DELETE")
+	allowHeaders := param("allow-headers", req.Header.Get("Access-Control-Request-Headers"))
+	maxAge := param("max-age", "600")
+
+	if strings.ContainsAny(allowOrigin+allowMethods+allowHeaders+maxAge, "\r\n") {
+		badRequest(rw, errors.New("header values must not contain line breaks"))
+		return
+	}
+
+	if allowOrigin != "" {
+		rw.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	}
+	rw.Header().Add("Vary", "Origin")
+
+	if req.Method != http.MethodOptions {
+		slog.InfoContext(ctx, "serving CORS request", "origin", req.Header.Get("Origin"), "allow_origin", allowOrigin)
+
+		http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
+		return
+	}
+
+	delay, errDelay := queryDuration(query, "delay", time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return
+	}
+
+	slog.InfoContext(ctx, "delaying preflight", "delay", delay,
+		"origin", req.Header.Get("Origin"),
+		"request_method", req.Header.Get("Access-Control-Request-Method"))
+
+	if sleepCtx(ctx, delay) != nil {
+		return
+	}
+
+	for name, value := range map[string]string{
+		"Access-Control-Allow-Methods": allowMethods,
+		"Access-Control-Allow-Headers": allowHeaders,
+		"Access-Control-Max-Age":       maxAge,
+	} {
+		if value != "" {
+			rw.Header().Set(name, value)
+		}
+	}
+
+	slog.InfoContext(ctx, "answering preflight", "allow_origin", allowOrigin,
+		"allow_methods", allowMethods, "allow_headers", allowHeaders, "max_age", maxAge)
+
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errTruncated stops the file server once the truncated response is sent.
+var errTruncated = errors.New("response truncated")
+
+// resolveServeDir checks that dir is a directory and resolves symlinks in its path,
+// so served paths can be compared against it.
+func resolveServeDir(dir string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return resolved, nil
+}
+
+// insideDir reports whether the slash-separated name stays inside root after resolving symlinks.
+// Names of missing files are considered inside, the file server responds with 404 to them.
+func insideDir(root, name string) bool {
+	name = path.Clean("/" + name)
+	if strings.Contains(name, "\\") || strings.ContainsRune(name, 0) {
+		return false
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// serveFiles serves files and directory listings from -serve-dir by the request path,
+// with 'mode' misbehavior applied: none, slow or truncate.
+func (srv *service) serveFiles(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	if srv.serveDir == "" {
+		http.Error(rw, "serving files is disabled, set -serve-dir", http.StatusNotFound)
+		return nil
+	}
+
+	if !insideDir(srv.serveDir, req.URL.Path) {
+		slog.WarnContext(ctx, "path escapes served directory", "path", req.URL.Path)
+		http.Error(rw, "forbidden", http.StatusForbidden)
+		return nil
+	}
+
+	files := http.FileServer(http.Dir(srv.serveDir))
+
+	mode := query.Get("mode")
+	switch mode {
+	case "", "none":
+		files.ServeHTTP(rw, req)
+	case "slow":
+		interval, errRate := queryRate(query, 10)
+		if errRate != nil {
+			badRequest(rw, errRate)
+			return nil
+		}
+
+		slog.InfoContext(ctx, "serving file slowly", "path", req.URL.Path, "interval", interval)
+
+		files.ServeHTTP(&slowResponseWriter{ResponseWriter: rw, ctx: ctx, interval: interval}, req)
+	case "truncate":
+		after, errAfter := queryInt(query, "truncate-at", -1)
+		if errAfter != nil {
+			badRequest(rw, errAfter)
+			return nil
+		}
+
+		tw := &truncatingResponseWriter{ResponseWriter: rw, after: after}
+		files.ServeHTTP(tw, req)
+
+		if tw.err != nil && !errors.Is(tw.err, errTruncated) {
+			return tw.err
+		}
+
+		slog.InfoContext(ctx, "serving truncated file", "path", req.URL.Path, "written", tw.written, "truncated", tw.err != nil)
+	default:
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected none, slow or truncate", mode))
+	}
+
+	return nil
+}
+
+// slowResponseWriter writes the response body byte by byte, waiting for interval before each byte.
+type slowResponseWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	interval time.Duration
+}
+
+func (w *slowResponseWriter) Write(p []byte) (int, error) {
+	controller := http.NewResponseController(w.ResponseWriter)
+
+	for i := range p {
+		if errSleep := sleepCtx(w.ctx, w.interval); errSleep != nil {
+			return i, errSleep
+		}
+
+		if _, errWrite := w.ResponseWriter.Write(p[i : i+1]); errWrite != nil {
+			return i, errWrite
+		}
+		if errFlush := controller.Flush(); errFlush != nil {
+			return i, errFlush
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *slowResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// truncatingResponseWriter closes the connection after 'after' body bytes are written.
+// Negative 'after' truncates the body at a half of its Content-Length.
+type truncatingResponseWriter struct {
+	http.ResponseWriter
+	after   int
+	written int
+	err     error
+}
+
+func (w *truncatingResponseWriter) WriteHeader(code int) {
+	if w.after < 0 {
+		size, _ := strconv.Atoi(w.Header().Get("Content-Length"))
+		w.after = size / 2
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *truncatingResponseWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if w.after < 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(p[:min(len(p), w.after-w.written)])
+	w.written += n
+	if err != nil {
+		w.err = err
+		return n, err
+	}
+
+	if w.written < w.after {
+		return n, nil
+	}
+
+	w.err = errTruncated
+
+	controller := http.NewResponseController(w.ResponseWriter)
+	if errFlush := controller.Flush(); errFlush != nil {
+		w.err = errFlush
+		return n, w.err
+	}
+
+	if errClose := closeConn(w.ResponseWriter); errClose != nil {
+		w.err = errClose
+	}
+
+	return n, w.err
+}
+
+func (w *truncatingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
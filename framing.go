@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// http09 responds in HTTP/0.9 style: the bare body without status line and headers,
+// terminated by closing the connection.
+func http09(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	slog.InfoContext(ctx, "writing HTTP/0.9 response", "request_proto", req.Proto, "bytes", len(limeric))
+
+	if err := writeRaw(rw, []byte(limeric)); err != nil {
+		return fmt.Errorf("HTTP/0.9 response: %w", err)
+	}
+
+	return nil
+}
+
+type rawSegment struct {
+	name string
+	data string
+}
+
+// headerBodyInterleavings lists response segments for delayed-header-completion-with-partial-body modes.
+var headerBodyInterleavings = map[string]func(body string) []rawSegment{
+	// body starts after a header line, the rest of headers follows the body part
+	"interleaved": func(body string) []rawSegment {
+		half := len(body) / 2
+		return []rawSegment{
+			{"status-and-headers", "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n"},
+			{"body-part", body[:half]},
+			{"rest-of-headers", "\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\nConnection: close\r\n\r\n"},
+			{"rest-of-body", body[half:]},
+		}
+	},
+	// header block is never terminated by an empty line
+	"no-terminator": func(body string) []rawSegment {
+		return []rawSegment{
+			{"status-and-headers", "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n"},
+			{"body", body},
+		}
+	},
+	// header block is terminated by a single CRLF pair only after the body has started
+	"late-terminator": func(body string) []rawSegment {
+		half := len(body) / 2
+		return []rawSegment{
+			{"status-and-headers", "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n"},
+			{"body-part", body[:half]},
+			{"header-terminator", "\r\n\r\n"},
+			{"rest-of-body", body[half:]},
+		}
+	},
+}
+
+// delayedHeaderCompletion writes header and body segments interleaved according to 'mode',
+// waiting 'delay' between segments.
+func delayedHeaderCompletion(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "interleaved"
+	}
+
+	interleave, ok := headerBodyInterleavings[mode]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected interleaved, no-terminator or late-terminator", mode))
+		return nil
+	}
+
+	delay, errDelay := queryDuration(query, "delay", 500*time.Millisecond)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	for i, segment := range interleave(limeric) {
+		if i > 0 {
+			if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+				return errSleep
+			}
+		}
+
+		slog.InfoContext(ctx, "writing response segment", "mode", mode, "segment", segment.name, "bytes", len(segment.data))
+
+		if _, errWrite := w.WriteString(segment.data); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+	}
+
+	return nil
+}
+
+// multipleContentType sends two conflicting Content-Type headers, 'first' and 'second', with the limeric.
+func multipleContentType(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	first, second := query.Get("first"), query.Get("second")
+	if first == "" {
+		first = "text/plain"
+	}
+	if second == "" {
+		second = "application/json"
+	}
+
+	if strings.ContainsAny(first+second, "\r\n") {
+		badRequest(rw, errors.New("content types must not contain line breaks"))
+		return nil
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: ", first, "\r\n",
+		"Content-Type: ", second, "\r\n",
+		"Content-Length: ", strconv.Itoa(len(limeric)), "\r\n",
+		"Connection: close\r\n\r\n",
+		limeric,
+	)
+
+	slog.InfoContext(ctx, "writing conflicting content types", "first", first, "second", second)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("multiple content types: %w", err)
+	}
+
+	return nil
+}
+
+// headerCasings transform header names for header-case-variations action.
+var headerCasings = map[string]func(name string, rnd *lockedRand) string{
+	"lower": func(name string, _ *lockedRand) string { return strings.ToLower(name) },
+	"upper": func(name string, _ *lockedRand) string { return strings.ToUpper(name) },
+	"random": func(name string, rnd *lockedRand) string {
+		b := []byte(name)
+		for i := range b {
+			if rnd.Float64() < 0.5 {
+				b[i] = byte(unicode.ToUpper(rune(b[i])))
+			} else {
+				b[i] = byte(unicode.ToLower(rune(b[i])))
+			}
+		}
+		return string(b)
+	},
+}
+
+// headerCaseVariations sends the limeric with header names in 'case' casing: lower, upper or random.
+func (srv *service) headerCaseVariations(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	casing := req.URL.Query().Get("case")
+	if casing == "" {
+		casing = "random"
+	}
+
+	transform, ok := headerCasings[casing]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown case %q, expected lower, upper or random", casing))
+		return nil
+	}
+
+	headers := []kvPair{
+		{"Content-Type", "text/plain"},
+		{"Content-Length", strconv.Itoa(len(limeric))},
+		{"Cache-Control", "no-cache"},
+		{"Connection", "close"},
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp, "HTTP/1.1 200 OK\r\n")
+
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		name := transform(header.key, srv.rnd)
+		names = append(names, name)
+		writeStrs(resp, name, ": ", header.value, "\r\n")
+	}
+	writeStrs(resp, "\r\n", limeric)
+
+	slog.InfoContext(ctx, "writing headers with unusual casing", "case", casing, "headers", names)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("header case variations: %w", err)
+	}
+
+	return nil
+}
+
+// chunkedZeroSizedChunks sends the limeric lines as chunks with a stray zero-size chunk
+// after 'zero-at' chunks, followed by the rest of chunks and the real terminator.
+func chunkedZeroSizedChunks(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	lines := strings.SplitAfter(strings.TrimSuffix(limeric, "\n"), "\n")
+
+	zeroAt, errZeroAt := queryInt(req.URL.Query(), "zero-at", 1)
+	if errZeroAt == nil && zeroAt > len(lines) {
+		errZeroAt = fmt.Errorf("zero-at must not exceed %d", len(lines))
+	}
+	if errZeroAt != nil {
+		badRequest(rw, errZeroAt)
+		return nil
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Transfer-Encoding: chunked\r\n",
+		"Connection: close\r\n\r\n",
+	)
+
+	sequence := []int{}
+	for i, line := range lines {
+		if i == zeroAt {
+			resp.WriteString("0\r\n\r\n")
+			sequence = append(sequence, 0)
+		}
+		writeStrs(resp, strconv.FormatInt(int64(len(line)), 16), "\r\n", line, "\r\n")
+		sequence = append(sequence, len(line))
+	}
+	if zeroAt == len(lines) {
+		resp.WriteString("0\r\n\r\n")
+		sequence = append(sequence, 0)
+	}
+	resp.WriteString("0\r\n\r\n")
+	sequence = append(sequence, 0)
+
+	slog.InfoContext(ctx, "writing chunks with stray zero-size chunk", "zero_at", zeroAt, "chunk_sizes", sequence)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("chunked zero-sized chunks: %w", err)
+	}
+
+	return nil
+}
+
+// responseWithWrongHTTPVersion responds with the 'version' status line protocol version,
+// by default the opposite of the request one: HTTP/1.0 to HTTP/1.1 requests and vice versa.
+// HTTP/1.0 responses close the connection, HTTP/1.1 ones keep it open until the client sends
+// anything else, as the hijacked connection can't serve further requests.
+func responseWithWrongHTTPVersion(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	version := req.URL.Query().Get("version")
+	switch {
+	case version != "":
+	case req.ProtoAtLeast(1, 1):
+		version = "HTTP/1.0"
+	default:
+		version = "HTTP/1.1"
+	}
+
+	if strings.ContainsAny(version, " \r\n") {
+		badRequest(rw, fmt.Errorf("malformed version %q", version))
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	keepAlive := version == "HTTP/1.1"
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		version, " 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(len(limeric)), "\r\n\r\n",
+		limeric,
+	)
+
+	slog.InfoContext(ctx, "writing response with mismatched version",
+		"request_version", req.Proto, "response_version", version, "keep_alive", keepAlive)
+
+	defer conn.Close()
+
+	if _, errWrite := w.Write(resp.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	if keepAlive {
+		// blocks until the next request or EOF
+		_, _ = w.Reader.Peek(1)
+	}
+
+	return nil
+}
+
+// truncateResponseHeaders writes first 'after' bytes of the response header block and closes the connection.
+func truncateResponseHeaders(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	resp := limericResponse(req)
+	headerSize := bytes.Index(resp, []byte("\r\n\r\n")) + len("\r\n\r\n")
+
+	after, errAfter := queryInt(req.URL.Query(), "after", headerSize/2)
+	if errAfter == nil && after >= headerSize {
+		errAfter = fmt.Errorf("after must be less than header size %d", headerSize)
+	}
+	if errAfter != nil {
+		badRequest(rw, errAfter)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "truncating response headers", "after", after, "header_size", headerSize)
+
+	if err := writeRaw(rw, resp[:after]); err != nil {
+		return fmt.Errorf("truncated headers: %w", err)
+	}
+
+	return nil
+}
+
+// malformedContentLengths are Content-Length values for content-length-negative-or-huge action.
+var malformedContentLengths = map[string]string{
+	"negative":    "-1",
+	"non-numeric": "twelve",
+	// exceeds math.MaxInt64
+	"huge": "99999999999999999999999",
+	// fits int64, but hardly fits anywhere else
+	"max-int64": strconv.FormatInt(math.MaxInt64, 10),
+}
+
+// contentLengthMalformed sends a short body with Content-Length malformed according to 'mode'
+// and closes the connection.
+func contentLengthMalformed(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	mode := req.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "huge"
+	}
+
+	value, ok := malformedContentLengths[mode]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected negative, non-numeric, huge or max-int64", mode))
+		return nil
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", value, "\r\n",
+		"Connection: close\r\n\r\n",
+		limeric,
+	)
+
+	slog.InfoContext(ctx, "writing malformed content length", "mode", mode, "content_length", value)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("malformed content length: %w", err)
+	}
+
+	return nil
+}
+
+// respondWithReasonPhrase responds with 'code' status and a custom 'reason' phrase,
+// which may be empty or repeated up to 'reason-length' bytes.
+func respondWithReasonPhrase(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	code, errCode := queryInt(query, "code", http.StatusOK)
+	if errCode == nil && (code < 100 || code > 999) {
+		errCode = errors.New("code must have 3 digits")
+	}
+	if errCode != nil {
+		badRequest(rw, errCode)
+		return nil
+	}
+
+	reason := "Totally Fine"
+	if query.Has("reason") {
+		reason = query.Get("reason")
+	}
+
+	length, errLength := queryInt(query, "reason-length", len(reason))
+	if errLength == nil && length > 0 && reason == "" {
+		errLength = errors.New("reason-length requires non-empty reason")
+	}
+	if errLength == nil && length > maxGeneratedHeaderBytes {
+		errLength = fmt.Errorf("reason-length must not exceed %d", maxGeneratedHeaderBytes)
+	}
+	if errLength != nil {
+		badRequest(rw, errLength)
+		return nil
+	}
+	if length > 0 {
+		reason = strings.Repeat(reason, length/len(reason)+1)[:length]
+	}
+
+	if strings.ContainsAny(reason, "\r\n") {
+		badRequest(rw, fmt.Errorf("malformed reason %q", reason))
+		return nil
+	}
+
+	statusLine := "HTTP/1.1 " + strconv.Itoa(code) + " " + reason
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		statusLine, "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(len(limeric)), "\r\n",
+		"Connection: close\r\n\r\n",
+		limeric,
+	)
+
+	slog.InfoContext(ctx, "writing custom reason phrase", "status_line", statusLine, "reason_length", len(reason))
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("custom reason phrase: %w", err)
+	}
+
+	return nil
+}
+
+// earlyHintsThenError sends 103 Early Hints with 'link' headers and, after 'delay',
+// a final 'status' response, which is an error by default.
+func earlyHintsThenError(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	links := query["link"]
+	if len(links) == 0 {
+		links = []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"}
+	}
+	for _, link := range links {
+		if strings.ContainsAny(link, "\r\n") {
+			badRequest(rw, fmt.Errorf("malformed link %q", link))
+			return nil
+		}
+	}
+
+	status, errStatus := queryInt(query, "status", http.StatusInternalServerError)
+	if errStatus == nil && (status < 200 || status > 599) {
+		errStatus = errors.New("status must be a final status from 200 to 599")
+	}
+	if errStatus != nil {
+		badRequest(rw, errStatus)
+		return nil
+	}
+
+	delay, errDelay := queryDuration(query, "delay", 100*time.Millisecond)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	hints := &bytes.Buffer{}
+	writeStrs(hints, "HTTP/1.1 103 Early Hints\r\n")
+	for _, link := range links {
+		writeStrs(hints, "Link: ", link, "\r\n")
+	}
+	writeStrs(hints, "\r\n")
+
+	body := http.StatusText(status) + "\n"
+	final := &bytes.Buffer{}
+	writeStrs(final,
+		"HTTP/1.1 ", strconv.Itoa(status), " ", http.StatusText(status), "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(len(body)), "\r\n",
+		"Connection: close\r\n\r\n",
+		body,
+	)
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	slog.InfoContext(ctx, "writing early hints", "links", links)
+
+	if _, errWrite := w.Write(hints.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing early hints: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing early hints: %w", errFlush)
+	}
+
+	if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+		return errSleep
+	}
+
+	slog.InfoContext(ctx, "writing final response after early hints", "status", status, "delay", delay)
+
+	if _, errWrite := w.Write(final.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	return nil
+}
+
+// writeBeyondContentLength declares 'content-length' bytes, but writes 'extra' bytes more
+// before closing the connection. The response is keep-alive, so a client reusing
+// the connection takes the extra bytes for the next response.
+func writeBeyondContentLength(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	declared, errDeclared := queryInt(query, "content-length", len(limeric)/2)
+	if errDeclared != nil {
+		badRequest(rw, errDeclared)
+		return nil
+	}
+
+	extra, errExtra := queryInt(query, "extra", len(limeric)/2)
+	if errExtra != nil {
+		badRequest(rw, errExtra)
+		return nil
+	}
+
+	if extra > math.MaxInt-declared {
+		badRequest(rw, fmt.Errorf("content-length and extra must not exceed %d in total", math.MaxInt))
+		return nil
+	}
+	total := declared + extra
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+	defer conn.Close()
+
+	writeStrs(w,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(declared), "\r\n\r\n",
+	)
+
+	// the body may be huge, it's generated on the fly
+	n, errWrite := writeGenerated(w, total, func(i int) byte { return limeric[i%len(limeric)] })
+	if errWrite == nil {
+		errWrite = w.Flush()
+	}
+	// bytes left in the buffer never reached the client
+	written := max(n-w.Writer.Buffered(), 0)
+
+	slog.InfoContext(ctx, "wrote body beyond content length",
+		"declared", declared, "extra", extra, "planned", total, "written", written, "error", errWrite)
+
+	if errWrite != nil {
+		return fmt.Errorf("body beyond content length: %w", errWrite)
+	}
+
+	return nil
+}
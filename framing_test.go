@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTruncateResponseHeaders(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	// cut inside the status line, right after it, inside a header name and inside a value
+	for _, query := range []string{"", "&after=5", "&after=17", "&after=20", "&after=40"} {
+		t.Run(query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/?action=truncate-response-headers" + query)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("got response %s, want truncated headers to fail", resp.Status)
+			}
+
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !strings.Contains(err.Error(), "malformed") {
+				t.Errorf("got %v, want unexpected EOF or malformed response error", err)
+			}
+		})
+	}
+}
+
+func TestWriteBeyondContentLengthOverflow(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	resp := get(t, ts.URL+"/?action=write-beyond-content-length&content-length=9223372036854775807&extra=1")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+func gzipLimeric() []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, _ = gz.Write([]byte(limeric))
+	_ = gz.Close()
+
+	return buf.Bytes()
+}
+
+// gzipWithTrailingBytes sends a gzipped limeric followed by 'trailing' garbage bytes.
+// Content-Length covers the garbage, so the client reads it as a part of the body.
+func gzipWithTrailingBytes(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	trailing, errTrailing := queryInt(req.URL.Query(), "trailing", 16)
+	if errTrailing != nil {
+		badRequest(rw, errTrailing)
+		return nil
+	}
+
+	body := gzipLimeric()
+	if trailing > math.MaxInt-len(body) {
+		badRequest(rw, fmt.Errorf("trailing must not exceed %d", math.MaxInt-len(body)))
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+	defer conn.Close()
+
+	slog.InfoContext(ctx, "writing gzip with trailing bytes", "gzip_bytes", len(body), "trailing", trailing)
+
+	writeStrs(w,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Encoding: gzip\r\n",
+		"Content-Length: ", strconv.Itoa(len(body)+trailing), "\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	_, _ = w.Write(body)
+
+	// the garbage may be huge, it's generated on the fly
+	_, errWrite := writeGenerated(w, trailing, func(i int) byte { return byte(0xA5 ^ i) })
+	if errWrite == nil {
+		errWrite = w.Flush()
+	}
+	if errWrite != nil {
+		return fmt.Errorf("gzip with trailing bytes: %w", errWrite)
+	}
+
+	return nil
+}
+
+// contentEncodingIdentityLie declares 'Content-Encoding: identity', but sends a gzipped limeric.
+// Pass 'compress=false' to send the plain limeric, as declared.
+func contentEncodingIdentityLie(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	compress, errCompress := queryBool(req.URL.Query(), "compress", true)
+	if errCompress != nil {
+		badRequest(rw, errCompress)
+		return nil
+	}
+
+	body := []byte(limeric)
+	if compress {
+		body = gzipLimeric()
+		slog.InfoContext(ctx, "sending gzip body declared as identity encoding", "bytes", len(body))
+	}
+
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Header().Set("Content-Encoding", "identity")
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	if _, errWrite := rw.Write(body); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
+
+// gzipMultistream sends the limeric split into 'members' parts, each compressed as a separate gzip member.
+// There are at most as many members as limeric bytes.
+// Decoders reading only the first member get only the first part.
+func gzipMultistream(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	members, errMembers := queryInt(req.URL.Query(), "members", 3)
+	if errMembers == nil && members == 0 {
+		errMembers = errors.New("members must be positive")
+	}
+	if errMembers != nil {
+		badRequest(rw, errMembers)
+		return nil
+	}
+	// every member gets at least a byte of the limeric
+	requested := members
+	members = min(members, len(limeric))
+
+	body := &bytes.Buffer{}
+	for i := 0; i < members; i++ {
+		gz := gzip.NewWriter(body)
+		_, _ = gz.Write([]byte(limeric[i*len(limeric)/members : (i+1)*len(limeric)/members]))
+		_ = gz.Close()
+	}
+
+	slog.InfoContext(ctx, "writing gzip multistream", "members", members, "requested_members", requested, "bytes", body.Len())
+
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Header().Set("Content-Encoding", "gzip")
+	rw.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+
+	if _, errWrite := rw.Write(body.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// getEncoded fetches the url, leaving the body as sent, without transparent decompression.
+func getEncoded(t *testing.T, url string) (*http.Response, []byte) {
+	t.Helper()
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	t.Cleanup(client.CloseIdleConnections)
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	return resp, body
+}
+
+func gunzip(t *testing.T, body []byte, multistream bool) (string, error) {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("reading gzip header: %v", err)
+	}
+	gz.Multistream(multistream)
+
+	plain, err := io.ReadAll(gz)
+
+	return string(plain), err
+}
+
+func TestGzipMultistream(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	for _, members := range []string{"1", "3", "7", "1000000"} {
+		t.Run(members, func(t *testing.T) {
+			_, body := getEncoded(t, ts.URL+"/?action=gzip-multistream&members="+members)
+
+			plain, err := gunzip(t, body, true)
+			if err != nil || plain != limeric {
+				t.Errorf("multistream reader got %q, %v, want the limeric", plain, err)
+			}
+
+			first, err := gunzip(t, body, false)
+			if err != nil {
+				t.Fatalf("reading first member: %v", err)
+			}
+			if members == "1" {
+				if first != limeric {
+					t.Errorf("single member is %q, want the limeric", first)
+				}
+				return
+			}
+			if first == "" || len(first) >= len(limeric) || first != limeric[:len(first)] {
+				t.Errorf("first member is %q, want a prefix of the limeric", first)
+			}
+		})
+	}
+}
+
+func TestGzipWithTrailingBytes(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	resp, body := getEncoded(t, ts.URL+"/?action=gzip-with-extra-trailing-bytes")
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("Content-Encoding %q, want gzip", encoding)
+	}
+
+	single, errSingle := gunzip(t, body, false)
+	if errSingle != nil || single != limeric {
+		t.Errorf("single member reader got %q, %v, want the limeric", single, errSingle)
+	}
+
+	// the default reader takes the trailing bytes for the next member header
+	multi, errMulti := gunzip(t, body, true)
+	if errMulti == nil {
+		t.Errorf("default reader got %q without an error, want trailing bytes to fail it", multi)
+	}
+}
+
+func TestContentEncodingIdentityLie(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	resp, body := getEncoded(t, ts.URL+"/?action=content-encoding-identity-lie")
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "identity" {
+		t.Errorf("Content-Encoding %q, want identity", encoding)
+	}
+	if !bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		t.Errorf("body starts with % x, want gzip magic 1f 8b", body[:min(len(body), 2)])
+	}
+
+	_, plain := getEncoded(t, ts.URL+"/?action=content-encoding-identity-lie&compress=false")
+	if string(plain) != limeric {
+		t.Errorf("compress=false body is %q, want the limeric", plain)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+// parseAcceptLanguage returns language ranges with non-zero q-values, most preferred first.
+func parseAcceptLanguage(header string) []languageRange {
+	var ranges []languageRange
+	for _, field := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(field, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	return ranges
+}
+
+// negotiateLanguage picks the most preferred available language.
+// A range matches a language if it's equal to it or to its prefix, like "en" for "en-us".
+func negotiateLanguage(ranges []languageRange, available []kvPair) (kvPair, bool) {
+	for _, r := range ranges {
+		for _, lang := range available {
+			tag := strings.ToLower(lang.key)
+			if r.tag == "*" || r.tag == tag || strings.HasPrefix(tag, r.tag+"-") {
+				return lang, true
+			}
+		}
+	}
+
+	return kvPair{}, false
+}
+
+// responsePerAcceptLanguage selects the body from 'languages' lang=body pairs by Accept-Language header.
+// If no language matches, the first one is served or, with 'strict' set, 406 is returned.
+func responsePerAcceptLanguage(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	languages := []kvPair{{key: "en", value: limeric}}
+	if query.Get("languages") != "" {
+		parsed, errLanguages := parseKVPairs("languages", query.Get("languages"))
+		if errLanguages != nil {
+			badRequest(rw, errLanguages)
+			return nil
+		}
+		languages = parsed
+	}
+
+	strict, errStrict := queryBool(query, "strict", false)
+	if errStrict != nil {
+		badRequest(rw, errStrict)
+		return nil
+	}
+
+	acceptLanguage := req.Header.Get("Accept-Language")
+	rw.Header().Set("Vary", "Accept-Language")
+
+	lang, ok := negotiateLanguage(parseAcceptLanguage(acceptLanguage), languages)
+	switch {
+	case !ok && strict:
+		slog.InfoContext(ctx, "no acceptable language", "accept_language", acceptLanguage)
+		http.Error(rw, "no acceptable language", http.StatusNotAcceptable)
+		return nil
+	case !ok:
+		lang = languages[0]
+	}
+
+	slog.InfoContext(ctx, "negotiated language", "accept_language", acceptLanguage, "language", lang.key)
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("Content-Language", lang.key)
+
+	if _, errWrite := rw.Write([]byte(lang.value)); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// oscillatingLatency delays the response following a sine wave between 'min' and 'max'
+// with the 'period' over the wall clock time.
+func oscillatingLatency(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	minDelay, errMin := queryDuration(query, "min", 0)
+	if errMin != nil {
+		badRequest(rw, errMin)
+		return
+	}
+
+	maxDelay, errMax := queryDuration(query, "max", 2*time.Second)
+	if errMax == nil && maxDelay < minDelay {
+		errMax = errors.New("max must not be less than min")
+	}
+	if errMax != nil {
+		badRequest(rw, errMax)
+		return
+	}
+
+	period, errPeriod := queryDuration(query, "period", time.Minute)
+	if errPeriod == nil && period == 0 {
+		errPeriod = errors.New("period must be positive")
+	}
+	if errPeriod != nil {
+		badRequest(rw, errPeriod)
+		return
+	}
+
+	phase := float64(time.Now().UnixNano()%int64(period)) / float64(period)
+	wave := (1 + math.Sin(2*math.Pi*phase)) / 2
+	delay := minDelay + time.Duration(wave*float64(maxDelay-minDelay))
+
+	slog.InfoContext(ctx, "oscillating latency", "delay", delay, "phase", phase)
+
+	if sleepCtx(ctx, delay) != nil {
+		return
+	}
+
+	http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
+}
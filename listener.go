@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// onConnectActions are performed on a fresh connection before any request is read.
+// They report whether the connection must be served afterwards.
+var onConnectActions = map[string]func(conn net.Conn) (bool, error){
+	"close": func(conn net.Conn) (bool, error) {
+		return false, conn.Close()
+	},
+	"garbage": func(conn net.Conn) (bool, error) {
+		_, err := conn.Write([]byte("\x00\xffgarbage\r\n\x1b[0m"))
+		return true, err
+	},
+	"response": func(conn net.Conn) (bool, error) {
+		_, err := conn.Write([]byte("HTTP/1.1 200 OK\r\n" +
+			"Content-Type: text/plain\r\n" +
+			"Content-Length: " + strconv.Itoa(len(limeric)) + "\r\n\r\n" +
+			limeric))
+		return true, err
+	},
+}
+
+func checkOnConnectAction(name string) error {
+	if _, ok := onConnectActions[name]; !ok {
+		return fmt.Errorf("unknown on-connect action %q, expected close, garbage or response", name)
+	}
+
+	return nil
+}
+
+// connListener assigns IDs to accepted connections and sets the on-connect action up on them.
+type connListener struct {
+	net.Listener
+	connIDs   atomic.Int64
+	onConnect string
+}
+
+// idConn runs the on-connect action before its first read or write,
+// so it's performed in the connection's own goroutine rather than blocking Accept.
+type idConn struct {
+	net.Conn
+	id        int64
+	onConnect string
+
+	onConnectOnce sync.Once
+	onConnectErr  error
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	conn, errAccept := l.Listener.Accept()
+	if errAccept != nil {
+		return nil, errAccept
+	}
+
+	return &idConn{Conn: conn, id: l.connIDs.Add(1), onConnect: l.onConnect}, nil
+}
+
+// runOnConnect performs the on-connect action once and returns an error
+// if the connection must not be served afterwards.
+func (c *idConn) runOnConnect() error {
+	c.onConnectOnce.Do(func() {
+		if c.onConnect == "" {
+			return
+		}
+
+		slog.Info("running on-connect action", "on_connect_action", c.onConnect, "conn_id", c.id)
+
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		serve, errAction := onConnectActions[c.onConnect](c.Conn)
+		_ = c.Conn.SetWriteDeadline(time.Time{})
+
+		switch {
+		case errAction != nil:
+			slog.Error("running on-connect action", "on_connect_action", c.onConnect, "conn_id", c.id, "error", errAction)
+			_ = c.Conn.Close()
+			c.onConnectErr = errAction
+		case !serve:
+			c.onConnectErr = net.ErrClosed
+		}
+	})
+
+	return c.onConnectErr
+}
+
+func (c *idConn) Read(p []byte) (int, error) {
+	if err := c.runOnConnect(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(p)
+}
+
+func (c *idConn) Write(p []byte) (int, error) {
+	if err := c.runOnConnect(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(p)
+}
+
+// acceptedConnID returns the ID assigned to the connection by connListener.
+func acceptedConnID(conn net.Conn) (int64, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	c, ok := conn.(*idConn)
+	if !ok {
+		return 0, false
+	}
+
+	return c.id, true
+}
+
+// tcpConn digs the underlying TCP connection out of TLS and connListener wrappers.
+func tcpConn(conn net.Conn) (*net.TCPConn, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	if c, ok := conn.(*idConn); ok {
+		conn = c.Conn
+	}
+
+	tcp, ok := conn.(*net.TCPConn)
+	return tcp, ok
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnConnectActionDoesNotBlockAccept(t *testing.T) {
+	// the first connection hangs in the action until the test ends
+	release := make(chan struct{})
+	var calls atomic.Int64
+	onConnectActions["test-block-first"] = func(net.Conn) (bool, error) {
+		if calls.Add(1) == 1 {
+			<-release
+		}
+		return true, nil
+	}
+	t.Cleanup(func() { delete(onConnectActions, "test-block-first") })
+
+	ts := httptest.NewUnstartedServer(&service{rnd: newLockedRand(1), logSample: 1, dumpOut: io.Discard})
+	ts.Listener = &connListener{Listener: ts.Listener, onConnect: "test-block-first"}
+	ts.Start()
+	t.Cleanup(ts.Close)
+	// the server can't close before the blocked connection is released
+	t.Cleanup(func() { close(release) })
+
+	blocked, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer blocked.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("on-connect action never ran")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("GET while another connection is in on-connect action: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d, want 200", resp.StatusCode)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log/slog"
+	"net"
 )
 
 type slogMeta struct {
@@ -13,13 +14,44 @@ type connIDCtxKey struct{}
 
 type requestIDKey struct{}
 
+// sampledOutKey marks requests excluded from logging by -log-sample.
+type sampledOutKey struct{}
+
+// RequestID returns the ID of the request being handled.
+// Actions get it in the request context, so their log records carry it as "request_id".
+func RequestID(ctx context.Context) (int64, bool) {
+	reqID, ok := ctx.Value(requestIDKey{}).(int64)
+	return reqID, ok
+}
+
+// ConnID returns the ID of the connection the request arrived on.
+// Actions get it in the request context, so their log records carry it as "conn_id".
+func ConnID(ctx context.Context) (int64, bool) {
+	connID, ok := ctx.Value(connIDCtxKey{}).(int64)
+	return connID, ok
+}
+
+// connContext puts the ID of connections accepted by connListener into their context.
+func connContext(ctx context.Context, conn net.Conn) context.Context {
+	connID, ok := acceptedConnID(conn)
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, connIDCtxKey{}, connID)
+}
+
 func (s *slogMeta) Handle(ctx context.Context, record slog.Record) error {
-	reqID, okReqID := ctx.Value(requestIDKey{}).(int64)
+	if sampledOut, _ := ctx.Value(sampledOutKey{}).(bool); sampledOut && record.Level < slog.LevelError {
+		return nil
+	}
+
+	reqID, okReqID := RequestID(ctx)
 	if okReqID {
 		record.Add("request_id", reqID)
 	}
 
-	connID, okConnID := ctx.Value(connIDCtxKey{}).(int64)
+	connID, okConnID := ConnID(ctx)
 	if okConnID {
 		record.Add("conn_id", connID)
 	}
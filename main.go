@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,9 +14,12 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -34,6 +39,73 @@ func main() {
 		return logLevel.UnmarshalText([]byte(s))
 	})
 
+	tlsCert, tlsKey := "", ""
+	flag.StringVar(&tlsCert, "tls-cert", tlsCert, "TLS certificate file, enables HTTPS if set together with -tls-key")
+	flag.StringVar(&tlsKey, "tls-key", tlsKey, "TLS private key file")
+
+	tlsConfig := &tls.Config{}
+	flag.Func("tls-min-version", "minimal TLS version: 1.0, 1.1, 1.2 or 1.3", func(s string) error {
+		version, err := parseTLSVersion(s)
+		tlsConfig.MinVersion = version
+		return err
+	})
+	flag.Func("tls-cipher", "comma-separated list of allowed TLS 1.0-1.2 cipher suites, insecure ones included; caps TLS version at 1.2", func(s string) error {
+		ciphers, err := parseCipherSuites(s)
+		tlsConfig.CipherSuites = ciphers
+		return err
+	})
+
+	tlsClientCA, tlsClientAuth := "", ""
+	flag.StringVar(&tlsClientCA, "tls-client-ca", tlsClientCA, "PEM file with CA certificates to verify TLS client certificates against")
+	flag.StringVar(&tlsClientAuth, "tls-client-auth", tlsClientAuth, "TLS client certificate mode: request or require, defaults to request if -tls-client-ca is set")
+
+	onConnect := ""
+	flag.Func("on-connect-action", "action to perform on accepted connection before reading a request: close, garbage or response", func(s string) error {
+		onConnect = s
+		return checkOnConnectAction(s)
+	})
+
+	seed := time.Now().UnixNano()
+	flag.Int64Var(&seed, "seed", seed, "seed for random behavior, defaults to the current time")
+
+	var responseDelay delayDistribution
+	flag.Func("response-delay-distribution", "delay distribution for normal responses: constant:D, uniform:MIN,MAX, exponential:MEAN or lognormal:MEDIAN,SIGMA", func(s string) error {
+		dist, err := parseDelayDistribution(s)
+		responseDelay = dist
+		return err
+	})
+
+	maxRequestLine := 0
+	flag.IntVar(&maxRequestLine, "max-request-line", maxRequestLine, "maximal request line length, longer requests get 414 URI Too Long, 0 disables the limit")
+
+	loadtest := false
+	flag.BoolVar(&loadtest, "loadtest", loadtest, "don't dump and log handled requests, report server stats periodically instead")
+
+	statsInterval := 5 * time.Second
+	flag.DurationVar(&statsInterval, "stats-interval", statsInterval, "interval between stats reports in -loadtest mode, 0 disables reports")
+
+	logSample := 1.0
+	flag.Func("log-sample", "fraction of requests to dump and log, from 0.0 to 1.0, errors are always logged, default: 1.0", func(s string) error {
+		value, err := strconv.ParseFloat(s, 64)
+		if err == nil && (value < 0 || value > 1) {
+			err = errors.New("must be in range from 0.0 to 1.0")
+		}
+		logSample = value
+		return err
+	})
+
+	serveDir := ""
+	flag.StringVar(&serveDir, "serve-dir", serveDir, "directory to serve files from with serve-file-listing action")
+
+	templateFile := ""
+	flag.StringVar(&templateFile, "template-file", templateFile, "text/template file to render responses from with respond-from-template-file-with-includes action")
+
+	pidFile := ""
+	flag.StringVar(&pidFile, "pid-file", pidFile, "file to write the process ID to, removed on shutdown")
+
+	readyFD := 0
+	flag.IntVar(&readyFD, "ready-fd", readyFD, "inherited file descriptor, 3 or above, to write a newline to and close once the server is listening, 0 disables it")
+
 	flag.Usage = func() {
 		output := flag.CommandLine.Output()
 		fmt.Fprintln(output,
@@ -42,7 +114,75 @@ func main() {
 			"Available actions:\n"+
 				"  - hang: server will hang on request until client closes connection\n"+
 				"  - close: server will close connection without HTTP response\n"+
-				"  - slow-write: server will write response slowly, byte by byte, 10 byte/s",
+				"  - slow-write: server will write response slowly, byte by byte, 10 byte/s\n"+
+				"  - slow-write-signal: server will send 'signal' (pause, resume, speed-up, abort) to in-flight slow-writes matching 'request-id' and 'conn-id' and report them as JSON\n"+
+				"  - meta-headers: server will respond with X-Meta-* headers built from 'meta' k=v pairs, repeated up to 'count'\n"+
+				"  - slow-write-aware-of-client-speed: server will write response byte by byte at 'rate' byte/s, logging how fast the client reads\n"+
+				"  - body-with-null-bytes: server will respond with 'size' bytes of NUL and control characters following 'pattern' (nul, control, mixed) as text/plain\n"+
+				"  - delayed-close-after-response: server will send a keep-alive response and close the idle connection after 'idle' delay, 5s by default\n"+
+				"  - multi-status: server will respond with 207 and WebDAV XML body built from 'resources' href=status pairs, 'mode=malformed' breaks the XML\n"+
+				"  - gzip-with-extra-trailing-bytes: server will send a gzip body followed by 'trailing' garbage bytes, 16 by default\n"+
+				"  - response-per-accept-language: server will pick the body from 'languages' lang=body pairs by Accept-Language, 406 with 'strict' if nothing matches\n"+
+				"  - truncate-at-content-type-boundary: server will declare a full 'content-type' body, send only 'offset' bytes of it and close connection\n"+
+				"  - slow-write-percentage-complete-then-hang: server will write 'percent' of the body at 'rate' byte/s and hang until client closes connection\n"+
+				"  - content-encoding-identity-lie: server will declare identity Content-Encoding, but send a gzipped body, unless 'compress=false'\n"+
+				"  - slow-write-with-tcp-push-flags: server will write response in separate TCP segments of 'segment-size' bytes each 'interval', 0 size lets the kernel coalesce them\n"+
+				"  - require-header: server will respond with 400 unless request has 'header', equal to 'value' if set\n"+
+				"  - stream-ndjson: server will stream 'count' JSON objects each 'interval', replacing object number 'error-at' with an error object and closing stream\n"+
+				"  - close-on-specific-byte-received: server will close connection after receiving 'close-after' body bytes or 'pattern' in the body\n"+
+				"  - reflect-cookies: server will respond with request cookies as JSON, values are hidden with 'redact=true'\n"+
+				"  - slow-write-resettable-timeout: server will stream limeric lines as SSE each 'delay', sending keepalive comments each 'keepalive-interval' meanwhile\n"+
+				"  - long-url: server will redirect to a URL padded to 'length' bytes, to be checked against -max-request-line\n"+
+				"  - body-with-bom: server will respond with limeric encoded as 'bom' (utf-8, utf-16le, utf-16be) with a byte-order mark, 'charset=false' omits charset\n"+
+				"  - partial-write-flush-boundaries: server will write body split at 'splits' byte offsets, flushing each part after 'interval'\n"+
+				"  - reflect-method: server will respond with the request method, or 405 if it's not in the 'allow' list\n"+
+				"  - slow-write-backpressure-report: server will write 'size' bytes at 'rate' byte/s, logging writes blocked by a slowly reading client\n"+
+				"  - http-0.9: server will respond with a bare body without status line and headers and close connection\n"+
+				"  - oscillating-latency: server will delay response following a sine wave between 'min' and 'max' with 'period'\n"+
+				"  - reject-compressed-request: server will respond with 415 if request Content-Encoding is in 'reject' list\n"+
+				"  - delayed-header-completion-with-partial-body: server will interleave headers and body according to 'mode' (interleaved, no-terminator, late-terminator)\n"+
+				"  - reflect-raw-request-line: server will respond with the request line as received\n"+
+				"  - slow-write-with-deadline-exceeded: server will write response at 'rate' byte/s with a write 'deadline', closing connection once it's exceeded\n"+
+				"  - multiple-content-type: server will send two conflicting Content-Type headers, 'first' and 'second'\n"+
+				"  - reflect-request-size: server will read the request body and respond with header, body and total request sizes as JSON\n"+
+				"  - serve-range-with-gaps: server will respond to Range request with 206, but send 'shortfall' bytes less than the range and close connection\n"+
+				"  - slow-write-utf8-split: server will write a multi-byte UTF-8 body at 'rate' parts/s, split according to 'split' (mid-rune, byte, rune)\n"+
+				"  - close-request-side-only: server will stop reading the request by closing read side of connection and respond after 'delay'\n"+
+				"  - gzip-multistream: server will send the body as 'members' concatenated gzip members\n"+
+				"  - respond-then-read-more: server will respond with 'status' before reading request body, then read the rest of it with 'mode=continue' or close connection after 'delay' with 'mode=stop'\n"+
+				"  - header-case-variations: server will send response header names in 'case' casing (lower, upper, random)\n"+
+				"  - throttle-ramp: server will write response byte by byte, changing rate from 'start-rate' to 'end-rate' byte/s following 'shape' (linear, exponential)\n"+
+				"  - reflect-auth: server will describe the Authorization header as JSON, with secrets redacted\n"+
+				"  - slow-preflight: server will answer CORS preflight after 'delay' with 'allow-origin', 'allow-methods', 'allow-headers' and 'max-age' values as is\n"+
+				"  - chunked-zero-sized-chunks: server will send chunked body with a stray zero-size chunk after 'zero-at' chunks\n"+
+				"  - response-with-wrong-http-version: server will respond with 'version' protocol version, the opposite of the request one by default\n"+
+				"  - delay-with-heartbeat: server will delay response by 'total', sending 'heartbeat' (whitespace, sse) each 'heartbeat-interval' meanwhile\n"+
+				"  - truncate-response-headers: server will close connection after writing 'after' bytes of response headers\n"+
+				"  - content-length-negative-or-huge: server will send Content-Length malformed according to 'mode' (negative, non-numeric, huge, max-int64)\n"+
+				"  - serve-file-listing: server will serve files and directory listings from -serve-dir by the request path, with 'mode' misbehavior (none, slow, truncate)\n"+
+				"  - reflect-tls-client-cert: server will describe the TLS client certificate as JSON, see -tls-client-ca and -tls-client-auth\n"+
+				"  - slow-write-with-content-length-update: server will declare 'content-length' bytes, write only 'sent' of them at 'rate' byte/s and never close connection\n"+
+				"  - respond-from-template-file-with-includes: server will respond with -template-file rendered with request data as 'content-type'\n"+
+				"  - connection-coalescing-bait: server will report TLS SNI of the connection and Host (:authority) of the request as JSON\n"+
+				"  - jittered-status-sequence: server will respond to successive requests with codes from 'sequence', each after a random delay between 'min-delay' and 'max-delay'\n"+
+				"  - reflect-forwarded-chain: server will respond with X-Forwarded-*, Forwarded and Via header chains as JSON, verbatim\n"+
+				"  - slow-write-then-upgrade-to-garbage: server will write response at 'rate' byte/s, replacing the body with garbage after 'switch-at' bytes\n"+
+				"  - respond-with-configurable-reason-phrase: server will respond with 'code' status and 'reason' phrase, repeated up to 'reason-length' bytes\n"+
+				"  - multipart-form-echo: server will respond with name, filename, content type and size of each multipart/form-data part as JSON, 'mode=mis-parse' rejects the body\n"+
+				"  - slow-write-resumes-after-tcp-window-probe: server will stop reading request body for 'stall', closing TCP window, then read it and report timings as JSON\n"+
+				"  - early-hints-then-error: server will send 103 Early Hints with 'link' headers and the final 'status' response after 'delay', 500 by default\n"+
+				"  - reflect-decoded-path: server will respond with raw and decoded request path, its segments and ';k=v' path parameters as JSON\n"+
+				"  - slow-write-with-periodic-flush-errors: server will write response at 'rate' byte/s, failing a write every 'error-every' bytes and resuming after 'glitch'\n"+
+				"  - respond-based-on-request-count-window: server will allow 'limit' requests per sliding 'window' from each client IP or 'token', responding with 429 and Retry-After to the rest\n"+
+				"  - malformed-multipart-boundary: server will respond with multipart 'type' (form-data, byteranges) body malformed according to 'mode' (mismatch, missing-close, no-boundary, none)\n"+
+				"  - delayed-eof: server will send a complete response with Connection: close, but close connection only after 'delay'\n"+
+				"  - reflect-if-conditions: server will respond with conditional request headers and 304/412 decision against 'etag' and 'last-modified' as JSON, 'mode=evaluate' applies it\n"+
+				"  - write-beyond-content-length: server will declare 'content-length' bytes, but write 'extra' bytes more and close connection\n"+
+				"  - slow-write-cancellable-via-header: server will write response at 'rate' byte/s and close connection at 'abort-at' bytes or percent, X-Abort-At header works too\n"+
+				"  - respond-with-link-header-pagination: server will respond with 'page' out of 'pages' and Link headers to other pages, malformed according to 'mode' (none, no-brackets, unterminated, self-loop)\n"+
+				"  - tarpit: server will send response headers and a body byte each 'interval', 1m by default, never completing the response\n"+
+				"  - reflect-expect-header: server will handle Expect: 100-continue according to 'continue' (send, delay, skip, reject) and report how it went as JSON\n"+
+				"  - respond-with-warning-header: server will respond with Warning headers from 'warnings' code=text pairs, malformed according to 'mode' (none, unquoted, no-agent, bad-date, empty)",
 		)
 
 		fmt.Fprintln(output, "\nFlags:")
@@ -57,23 +197,183 @@ func main() {
 	logger := slog.New(&slogMeta{logHandler})
 	slog.SetDefault(logger)
 
-	srv := &service{}
-	connIDs := new(atomic.Int64)
+	// a closed stdout pipe must fail dump writes instead of killing the process
+	signal.Ignore(syscall.SIGPIPE)
+
+	slog.Info("Random seed", "seed", seed)
+
+	if logSample < 1 {
+		slog.Info("Requests are sampled for logging", "log_sample", logSample)
+	}
+
+	if maxRequestLine > 0 {
+		slog.Info("Request line length is limited", "max_request_line", maxRequestLine)
+	}
+
+	if serveDir != "" {
+		resolved, errDir := resolveServeDir(serveDir)
+		if errDir != nil {
+			fmt.Fprintln(os.Stderr, "-serve-dir:", errDir)
+			os.Exit(2)
+		}
+		serveDir = resolved
+
+		slog.Info("Serving files", "serve_dir", serveDir)
+	}
+
+	if templateFile != "" {
+		resolved, errTemplate := filepath.EvalSymlinks(templateFile)
+		if errTemplate == nil {
+			resolved, errTemplate = filepath.Abs(resolved)
+		}
+		if errTemplate != nil {
+			fmt.Fprintln(os.Stderr, "-template-file:", errTemplate)
+			os.Exit(2)
+		}
+		templateFile = resolved
+
+		slog.Info("Rendering responses from template", "template_file", templateFile)
+	}
+
+	srv := &service{
+		rnd:            newLockedRand(seed),
+		responseDelay:  responseDelay,
+		maxRequestLine: maxRequestLine,
+		loadtest:       loadtest,
+		logSample:      logSample,
+		serveDir:       serveDir,
+		templateFile:   templateFile,
+	}
 	server := &http.Server{
 		Addr:              httpaddr,
 		ReadHeaderTimeout: time.Hour,
 		Handler:           srv,
 		ErrorLog:          slog.NewLogLogger(logHandler.WithGroup("net/http"), slog.LevelDebug),
-		ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
-			connID := connIDs.Add(1)
+		ConnState:         srv.stats.connState,
+		ConnContext:       connContext,
+	}
+
+	useTLS := tlsCert != "" || tlsKey != ""
+	if !useTLS && (tlsConfig.MinVersion != 0 || tlsConfig.CipherSuites != nil) {
+		fmt.Fprintln(os.Stderr, "-tls-min-version and -tls-cipher require -tls-cert and -tls-key")
+		os.Exit(2)
+	}
+
+	if tlsClientCA != "" || tlsClientAuth != "" {
+		if !useTLS {
+			fmt.Fprintln(os.Stderr, "-tls-client-ca and -tls-client-auth require -tls-cert and -tls-key")
+			os.Exit(2)
+		}
+
+		if tlsClientCA != "" {
+			pool, errPool := loadCertPool(tlsClientCA)
+			if errPool != nil {
+				fmt.Fprintln(os.Stderr, "-tls-client-ca:", errPool)
+				os.Exit(2)
+			}
+			tlsConfig.ClientCAs = pool
+		}
+
+		if tlsClientAuth == "" {
+			tlsClientAuth = "request"
+		}
+
+		clientAuth, errAuth := parseClientAuth(tlsClientAuth, tlsConfig.ClientCAs != nil)
+		if errAuth != nil {
+			fmt.Fprintln(os.Stderr, "-tls-client-auth:", errAuth)
+			os.Exit(2)
+		}
+		tlsConfig.ClientAuth = clientAuth
+	}
+
+	if tlsConfig.CipherSuites != nil {
+		// TLS 1.3 suites aren't configurable, so the list is enforced by capping the version
+		if tlsConfig.MinVersion == tls.VersionTLS13 {
+			fmt.Fprintln(os.Stderr, "-tls-cipher requires TLS 1.2 or lower, but -tls-min-version is 1.3")
+			os.Exit(2)
+		}
+		tlsConfig.MaxVersion = tls.VersionTLS12
+
+		if !hasHTTP2Cipher(tlsConfig.CipherSuites) {
+			slog.Warn("-tls-cipher lacks ciphers required by HTTP/2, serving HTTP/1.1 only",
+				"tls_ciphers", cipherSuiteNames(tlsConfig.CipherSuites))
+
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+			tlsConfig.NextProtos = []string{"http/1.1"}
+		}
+	}
+
+	// the descriptor is closed once readiness is signalled, so stdio can't be used
+	if readyFD != 0 && readyFD < 3 {
+		fmt.Fprintln(os.Stderr, "-ready-fd must be 3 or above, stdin, stdout and stderr are not allowed")
+		os.Exit(2)
+	}
+
+	if statsInterval < 0 {
+		fmt.Fprintln(os.Stderr, "-stats-interval must not be negative")
+		os.Exit(2)
+	}
+
+	if loadtest {
+		slog.Info("Load test mode, requests are not logged", "stats_interval", statsInterval)
+
+		if statsInterval > 0 {
+			go srv.stats.report(statsInterval)
+		}
+	}
+
+	if pidFile != "" {
+		if errPID := writePIDFile(pidFile); errPID != nil {
+			panic("writing pid file: " + errPID.Error())
+		}
+		defer os.Remove(pidFile)
 
-			return context.WithValue(ctx, connIDCtxKey{}, connID)
-		},
+		slog.Info("PID file written", "pid_file", pidFile, "pid", os.Getpid())
 	}
 
-	slog.Info("Listening HTTP", "addr", httpaddr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	errServe := server.ListenAndServe()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	ln, errListen := net.Listen("tcp", httpaddr)
+	if errListen != nil {
+		panic("listening: " + errListen.Error())
+	}
+
+	if readyFD != 0 {
+		if errReady := signalReady(readyFD); errReady != nil {
+			panic("signalling readiness: " + errReady.Error())
+		}
+
+		slog.Info("Readiness signalled", "ready_fd", readyFD)
+	}
+
+	if onConnect != "" {
+		slog.Info("On-connect action enabled", "on_connect_action", onConnect)
+	}
+
+	listener := &connListener{Listener: ln, onConnect: onConnect}
+
+	var errServe error
+	if useTLS {
+		server.TLSConfig = tlsConfig
+
+		slog.Info("Listening HTTPS", "addr", httpaddr,
+			"tls_min_version", tlsVersionName(tlsConfig.MinVersion),
+			"tls_max_version", tlsVersionName(tlsConfig.MaxVersion),
+			"tls_ciphers", cipherSuiteNames(tlsConfig.CipherSuites),
+			"tls_client_auth", tlsConfig.ClientAuth.String())
+
+		errServe = server.ServeTLS(listener, tlsCert, tlsKey)
+	} else {
+		slog.Info("Listening HTTP", "addr", httpaddr)
+
+		errServe = server.Serve(listener)
+	}
 
 	switch {
 	case errServe == nil,
@@ -86,35 +386,85 @@ func main() {
 	}
 }
 
+// bodyConsumingActions handle the request body as it arrives, so it's excluded from the request dump.
+var bodyConsumingActions = map[string]bool{
+	"close-on-specific-byte-received":           true,
+	"reflect-request-size":                      true,
+	"close-request-side-only":                   true,
+	"respond-then-read-more":                    true,
+	"reflect-expect-header":                     true,
+	"reject-compressed-request":                 true,
+	"multipart-form-echo":                       true,
+	"slow-write-resumes-after-tcp-window-probe": true,
+}
+
 type service struct {
-	counter atomic.Int64
+	counter    atomic.Int64
+	slowWrites slowWriteRegistry
+	sequences  sequenceCounters
+	windows    slidingWindows
+	tarpitted  atomic.Int64
+	rnd        *lockedRand
+	// responseDelay is applied to normal responses, if set
+	responseDelay delayDistribution
+	// maxRequestLine is disabled if zero
+	maxRequestLine int
+	stats          stats
+	// loadtest disables request dumps and logging of handled requests
+	loadtest bool
+	// logSample is a fraction of requests to dump and log, errors are always logged
+	logSample float64
+	// dumpOut receives request dumps, stdout if nil
+	dumpOut io.Writer
+	// dumpDisabled is set once dumpOut fails to accept a request dump
+	dumpDisabled atomic.Bool
+	// serveDir is a resolved -serve-dir path, serving files is disabled if empty
+	serveDir string
+	// templateFile is a resolved -template-file path, templates are disabled if empty
+	templateFile string
 }
 
 func (srv *service) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	defer func() { srv.stats.observe(time.Since(start)) }()
+
 	ctx := context.WithValue(req.Context(), requestIDKey{}, srv.counter.Add(1))
+	sampled := srv.logSample >= 1 || srv.rnd.Float64() < srv.logSample
+	if !sampled {
+		ctx = context.WithValue(ctx, sampledOutKey{}, true)
+	}
+	req = req.WithContext(ctx)
 
-	dump, errInput := httputil.DumpRequest(req, true)
-	if errInput != nil {
-		slog.ErrorContext(ctx, "dumping request", errInput)
-		http.Error(rw, "bad request: "+errInput.Error(), http.StatusBadRequest)
+	requestLine := len(req.Method) + len(" ") + len(req.RequestURI) + len(" ") + len(req.Proto)
+	if srv.maxRequestLine > 0 && requestLine > srv.maxRequestLine {
+		slog.InfoContext(ctx, "request line too long", "length", requestLine, "limit", srv.maxRequestLine)
+		http.Error(rw, "request line too long", http.StatusRequestURITooLong)
 		return
 	}
 
-	msg := &strings.Builder{}
-
-	writeStrs(msg,
-		"---\n",
-		string(dump), "\n",
-		"---\n",
-	)
+	action := req.URL.Query().Get("action")
 
-	fmt.Println(msg)
+	if !srv.loadtest && sampled {
+		// actions consuming the body themselves need it untouched
+		if errInput := srv.dumpRequest(req, !bodyConsumingActions[action]); errInput != nil {
+			slog.ErrorContext(ctx, "dumping request", "error", errInput)
+			http.Error(rw, "bad request: "+errInput.Error(), http.StatusBadRequest)
+			return
+		}
 
-	action := req.URL.Query().Get("action")
-	slog.InfoContext(ctx, "handling", "action", action)
+		slog.InfoContext(ctx, "handling", "action", action)
+	}
 
 	switch action {
 	case "":
+		if srv.responseDelay != nil {
+			delay := srv.responseDelay(srv.rnd)
+			slog.DebugContext(ctx, "sampled response delay", "delay", delay)
+
+			if sleepCtx(ctx, delay) != nil {
+				return
+			}
+		}
 		http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
 	case "hang":
 		<-ctx.Done()
@@ -126,16 +476,279 @@ func (srv *service) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		}
 		return
 	case "slow-write":
-		if err := slowWrite(rw, req); err != nil {
+		if err := srv.slowWrite(rw, req); err != nil {
 			slog.ErrorContext(ctx, "writing response", "error", err)
 			http.Error(rw, "can't properly write response", http.StatusInternalServerError)
 		}
+	case "meta-headers":
+		if err := metaHeaders(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing meta headers", "error", err)
+		}
+	case "body-with-null-bytes":
+		if err := bodyWithNullBytes(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "delayed-close-after-response":
+		if err := delayedCloseAfterResponse(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "multi-status":
+		if err := multiStatus(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-signal":
+		if err := srv.slowWriteSignal(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "gzip-with-extra-trailing-bytes":
+		if err := gzipWithTrailingBytes(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "response-per-accept-language":
+		if err := responsePerAcceptLanguage(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "truncate-at-content-type-boundary":
+		if err := truncateAtContentTypeBoundary(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-percentage-complete-then-hang":
+		if err := slowWritePercentThenHang(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "content-encoding-identity-lie":
+		if err := contentEncodingIdentityLie(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-with-tcp-push-flags":
+		if err := slowWriteSegments(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "require-header":
+		requireHeader(rw, req)
+	case "stream-ndjson":
+		if err := streamNDJSON(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "close-on-specific-byte-received":
+		if err := closeOnBytesReceived(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+		}
+	case "reflect-cookies":
+		if err := reflectCookies(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-resettable-timeout":
+		if err := slowWriteKeepalive(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "long-url":
+		longURL(rw, req)
+	case "body-with-bom":
+		if err := bodyWithBOM(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "partial-write-flush-boundaries":
+		if err := partialWriteFlushBoundaries(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-method":
+		reflectMethod(rw, req)
+	case "slow-write-backpressure-report":
+		if err := slowWriteBackpressure(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "http-0.9":
+		if err := http09(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "oscillating-latency":
+		oscillatingLatency(rw, req)
+	case "reject-compressed-request":
+		if err := rejectCompressedRequest(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+		}
+	case "delayed-header-completion-with-partial-body":
+		if err := delayedHeaderCompletion(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-raw-request-line":
+		reflectRawRequestLine(rw, req)
+	case "slow-write-with-deadline-exceeded":
+		if err := slowWriteDeadline(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "multiple-content-type":
+		if err := multipleContentType(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-request-size":
+		if err := reflectRequestSize(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+			http.Error(rw, "can't read request body", http.StatusBadRequest)
+		}
+	case "serve-range-with-gaps":
+		if err := serveRangeWithGaps(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-utf8-split":
+		if err := slowWriteUTF8Split(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "close-request-side-only":
+		if err := closeRequestSideOnly(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "gzip-multistream":
+		if err := gzipMultistream(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "respond-then-read-more":
+		if err := respondThenReadMore(rw, req); err != nil {
+			slog.ErrorContext(ctx, "handling request", "error", err)
+		}
+	case "header-case-variations":
+		if err := srv.headerCaseVariations(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "throttle-ramp":
+		if err := throttleRamp(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-auth":
+		if err := reflectAuth(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-preflight":
+		slowPreflight(rw, req)
+	case "chunked-zero-sized-chunks":
+		if err := chunkedZeroSizedChunks(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "response-with-wrong-http-version":
+		if err := responseWithWrongHTTPVersion(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "delay-with-heartbeat":
+		if err := delayWithHeartbeat(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "truncate-response-headers":
+		if err := truncateResponseHeaders(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "content-length-negative-or-huge":
+		if err := contentLengthMalformed(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "serve-file-listing":
+		if err := srv.serveFiles(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-tls-client-cert":
+		if err := reflectTLSClientCert(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-with-content-length-update":
+		if err := slowWriteContentLengthUpdate(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "respond-from-template-file-with-includes":
+		if err := srv.respondFromTemplate(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "connection-coalescing-bait":
+		if err := connectionCoalescingBait(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "jittered-status-sequence":
+		if err := srv.jitteredStatusSequence(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-forwarded-chain":
+		if err := reflectForwardedChain(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-then-upgrade-to-garbage":
+		if err := slowWriteThenGarbage(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "respond-with-configurable-reason-phrase":
+		if err := respondWithReasonPhrase(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "multipart-form-echo":
+		if err := multipartFormEcho(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+		}
+	case "slow-write-resumes-after-tcp-window-probe":
+		if err := stallReadsForWindowProbe(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+		}
+	case "early-hints-then-error":
+		if err := earlyHintsThenError(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-decoded-path":
+		if err := reflectDecodedPath(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-with-periodic-flush-errors":
+		if err := slowWritePeriodicFlushErrors(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "respond-based-on-request-count-window":
+		if err := srv.respondByRequestCountWindow(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "malformed-multipart-boundary":
+		if err := malformedMultipartBoundary(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "delayed-eof":
+		if err := delayedEOF(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-if-conditions":
+		if err := reflectIfConditions(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "write-beyond-content-length":
+		if err := writeBeyondContentLength(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-cancellable-via-header":
+		if err := slowWriteCancellable(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "respond-with-link-header-pagination":
+		if err := respondWithLinkPagination(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "tarpit":
+		if err := srv.tarpit(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "reflect-expect-header":
+		if err := reflectExpectHeader(rw, req); err != nil {
+			slog.ErrorContext(ctx, "reading request", "error", err)
+		}
+	case "respond-with-warning-header":
+		if err := respondWithWarningHeaders(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
+	case "slow-write-aware-of-client-speed":
+		if err := slowWriteClientSpeed(rw, req); err != nil {
+			slog.ErrorContext(ctx, "writing response", "error", err)
+		}
 	default:
 		http.Error(rw, "unknown action", http.StatusBadRequest)
 	}
 }
 
-func slowWrite(rw http.ResponseWriter, req *http.Request) error {
+// slowWrite drips the response byte by byte.
+// The write can be paused, sped up or aborted with slow-write-signal action.
+func (srv *service) slowWrite(rw http.ResponseWriter, req *http.Request) error {
 	ctx := req.Context()
 	controller := http.NewResponseController(rw)
 
@@ -148,8 +761,78 @@ func slowWrite(rw http.ResponseWriter, req *http.Request) error {
 
 	defer conn.Close()
 
+	// a paused write would outlive the client otherwise
+	ctx, cancel := closeAware(ctx, w.Reader)
+	defer cancel()
+
 	slog.InfoContext(ctx, "writing slow response")
 
+	resp := limericResponse(req)
+
+	control, done := srv.slowWrites.register(ctx, len(resp))
+	defer done()
+
+	for i, b := range resp {
+		state, errWait := control.wait(ctx)
+		if errWait != nil {
+			return errWait
+		}
+
+		switch state {
+		case slowWriteAborted:
+			slog.InfoContext(ctx, "slow write aborted by signal", "written", i)
+			return nil
+		case slowWriteRunning:
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		_, errWrite := w.Write([]byte{b})
+		if errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		_ = w.Flush()
+
+		control.setWritten(i + 1)
+	}
+
+	return nil
+}
+
+// dumpRequest prints the request to stdout.
+// If stdout can't be written, e.g. it's a pipe closed by the reader,
+// the error is logged once and dumping is disabled for good.
+func (srv *service) dumpRequest(req *http.Request, body bool) error {
+	if srv.dumpDisabled.Load() {
+		return nil
+	}
+
+	dump, errDump := httputil.DumpRequest(req, body)
+	if errDump != nil {
+		return errDump
+	}
+
+	msg := &strings.Builder{}
+
+	writeStrs(msg,
+		"---\n",
+		string(dump), "\n",
+		"---\n",
+	)
+
+	out := srv.dumpOut
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if _, errWrite := fmt.Fprintln(out, msg); errWrite != nil && srv.dumpDisabled.CompareAndSwap(false, true) {
+		slog.ErrorContext(req.Context(), "writing request dump, dumping is disabled", "error", errWrite)
+	}
+
+	return nil
+}
+
+// limericResponse builds a raw HTTP/1.1 response with the limeric as a body.
+func limericResponse(req *http.Request) []byte {
 	resp := &bytes.Buffer{}
 	writeStrs(resp,
 		"HTTP/1.1 200 OK\r\n",
@@ -159,13 +842,15 @@ func slowWrite(rw http.ResponseWriter, req *http.Request) error {
 	)
 	resp.WriteString(limeric)
 
-	for _, b := range resp.Bytes() {
-		time.Sleep(100 * time.Millisecond)
-		_, errWrite := w.Write([]byte{b})
-		if errWrite != nil {
-			return fmt.Errorf("writing response: %w", errWrite)
-		}
-		_ = w.Flush()
+	return resp.Bytes()
+}
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(rw http.ResponseWriter, v any) error {
+	rw.Header().Set("Content-Type", "application/json")
+
+	if errEncode := json.NewEncoder(rw).Encode(v); errEncode != nil {
+		return fmt.Errorf("writing response: %w", errEncode)
 	}
 
 	return nil
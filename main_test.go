@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// TestMain runs the test binary as badserv itself if BADSERV_MAIN_ARGS is set,
+// so tests can check the process as a whole.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("BADSERV_MAIN_ARGS"); ok {
+		os.Args = append([]string{"badserv"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// newTestServer serves srv the way main does, with connection IDs in request contexts.
+// Request dumps are discarded, unless srv has its own dumpOut.
+func newTestServer(t *testing.T, srv *service) *httptest.Server {
+	t.Helper()
+
+	if srv.rnd == nil {
+		srv.rnd = newLockedRand(1)
+	}
+	if srv.logSample == 0 {
+		srv.logSample = 1
+	}
+	if srv.dumpOut == nil {
+		srv.dumpOut = io.Discard
+	}
+
+	ts := httptest.NewUnstartedServer(srv)
+	ts.Listener = &connListener{Listener: ts.Listener}
+	ts.Config.ConnContext = connContext
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// syncBuffer is written by server goroutines and read by the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// captureLogs redirects the default logger to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+
+	logs := &syncBuffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(&slogMeta{slog.NewTextHandler(logs, nil)}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return logs
+}
+
+func get(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	return resp
+}
+
+func TestLogRequestAndConnIDs(t *testing.T) {
+	logs := captureLogs(t)
+	ts := newTestServer(t, &service{})
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(ts.URL + "/?action=long-url&length=100")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+
+	// the line is logged by the action itself, with the context it got from ServeHTTP
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `msg="redirecting to long URL"`) {
+			if !strings.Contains(line, "request_id=1") || !strings.Contains(line, "conn_id=1") {
+				t.Fatalf("action log line misses request_id or conn_id: %s", line)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("no action log line in:\n%s", logs)
+}
+
+// brokenPipe fails every write as stdout piped to an exited process does.
+type brokenPipe struct {
+	mu     sync.Mutex
+	writes int
+}
+
+func (p *brokenPipe) Write([]byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.writes++
+	return 0, syscall.EPIPE
+}
+
+func TestDumpBrokenPipe(t *testing.T) {
+	logs := captureLogs(t)
+	pipe := &brokenPipe{}
+	ts := newTestServer(t, &service{dumpOut: pipe})
+
+	for i := 0; i < 3; i++ {
+		if resp := get(t, ts.URL+"/"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, resp.StatusCode)
+		}
+	}
+
+	pipe.mu.Lock()
+	writes := pipe.writes
+	pipe.mu.Unlock()
+	if writes != 1 {
+		t.Errorf("dump written %d times, want 1", writes)
+	}
+
+	if n := strings.Count(logs.String(), "dumping is disabled"); n != 1 {
+		t.Errorf("dump error logged %d times, want 1", n)
+	}
+}
+
+func TestDumpClosedStdout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("picking a port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating stdout pipe: %v", err)
+	}
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating ready pipe: %v", err)
+	}
+	defer ready.Close()
+
+	stderr := &syncBuffer{}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "BADSERV_MAIN_ARGS=-http "+addr+" -ready-fd 3")
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = []*os.File{readyW}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting badserv: %v", err)
+	}
+	stdoutW.Close()
+	readyW.Close()
+	// nobody reads the dumps: the first one gets EPIPE and SIGPIPE
+	stdout.Close()
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		_ = cmd.Wait()
+	})
+
+	if _, err := ready.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("waiting for readiness: %v\n%s", err, stderr)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			t.Fatalf("request %d: %v\n%s", i, err, stderr)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, resp.StatusCode)
+		}
+	}
+
+	if n := strings.Count(stderr.String(), "dumping is disabled"); n != 1 {
+		t.Errorf("dump error logged %d times, want 1:\n%s", n, stderr)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxGeneratedHeaderBytes bounds response headers generated from query parameters.
+// It's above header size limits of common clients, 10MB for Go and 256KB for curl.
+const maxGeneratedHeaderBytes = 16 << 20
+
+// metaHeaders responds with a series of X-Meta-* headers built from the 'meta' query parameter.
+// If 'count' exceeds the number of pairs, pairs are repeated in order, up to maxGeneratedHeaderBytes.
+func metaHeaders(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	pairs, errPairs := parseKVPairs("meta", query.Get("meta"))
+	if errPairs != nil {
+		badRequest(rw, errPairs)
+		return nil
+	}
+
+	count, errCount := queryInt(query, "count", len(pairs))
+	if errCount != nil {
+		badRequest(rw, errCount)
+		return nil
+	}
+
+	if count > 0 && len(pairs) == 0 {
+		badRequest(rw, errors.New("meta must contain at least one k=v pair"))
+		return nil
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp, "HTTP/1.1 200 OK\r\n")
+	for i := 0; i < count; i++ {
+		pair := pairs[i%len(pairs)]
+		writeStrs(resp, "X-Meta-", pair.key, ": ", pair.value, "\r\n")
+
+		if resp.Len() > maxGeneratedHeaderBytes {
+			badRequest(rw, fmt.Errorf("%d meta headers exceed %d bytes", count, maxGeneratedHeaderBytes))
+			return nil
+		}
+	}
+	writeStrs(resp,
+		"Content-Length: 0\r\n",
+		"Connection: close\r\n\r\n",
+	)
+
+	slog.InfoContext(ctx, "writing meta headers", "pairs", len(pairs), "count", count)
+
+	return writeRaw(rw, resp.Bytes())
+}
+
+// warningTexts are standard RFC 7234 warning texts, used if 'warnings' omits them.
+var warningTexts = map[string]string{
+	"110": "Response is Stale",
+	"111": "Revalidation Failed",
+	"112": "Disconnected Operation",
+	"113": "Heuristic Expiration",
+	"199": "Miscellaneous Warning",
+	"214": "Transformation Applied",
+	"299": "Miscellaneous Persistent Warning",
+}
+
+// respondWithWarningHeaders serves the limeric with a Warning header per 'warnings' code=text pair.
+// Codes aren't validated, so they can be malformed too. The 'mode' parameter breaks the header values:
+//
+//	none     - well-formed values
+//	unquoted - warn-text isn't quoted
+//	no-agent - warn-agent is omitted
+//	bad-date - warn-date isn't an HTTP date
+//	empty    - header values are empty
+func respondWithWarningHeaders(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	warningsStr := query.Get("warnings")
+	if warningsStr == "" {
+		warningsStr = "110=,112="
+	}
+
+	warnings, errWarnings := parseKVPairs("warnings", warningsStr)
+	if errWarnings != nil {
+		badRequest(rw, errWarnings)
+		return nil
+	}
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "none"
+	}
+
+	var format func(code, text string) string
+	switch mode {
+	case "none":
+		format = func(code, text string) string { return code + ` badserv "` + text + `"` }
+	case "unquoted":
+		format = func(code, text string) string { return code + " badserv " + text }
+	case "no-agent":
+		format = func(code, text string) string { return code + ` "` + text + `"` }
+	case "bad-date":
+		format = func(code, text string) string { return code + ` badserv "` + text + `" "yesterday"` }
+	case "empty":
+		format = func(string, string) string { return "" }
+	default:
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected none, unquoted, no-agent, bad-date or empty", mode))
+		return nil
+	}
+
+	values := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		text := warning.value
+		if text == "" {
+			text = warningTexts[warning.key]
+		}
+		values = append(values, format(warning.key, text))
+	}
+
+	slog.InfoContext(ctx, "writing warning headers", "mode", mode, "warnings", values)
+
+	rw.Header()["Warning"] = values
+	http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const multipartBoundary = "badserv-boundary"
+
+// multipartPart is a part of malformed-multipart-boundary response.
+type multipartPart struct {
+	headers []string
+	body    string
+}
+
+// limericParts splits the limeric into parts of multipart response of 'kind': form-data or byteranges.
+func limericParts(kind string) []multipartPart {
+	half := len(limeric) / 2
+
+	if kind == "byteranges" {
+		contentRange := func(start, end int) string {
+			return fmt.Sprintf("Content-Range: bytes %d-%d/%d", start, end-1, len(limeric))
+		}
+
+		return []multipartPart{
+			{headers: []string{"Content-Type: text/plain", contentRange(0, half)}, body: limeric[:half]},
+			{headers: []string{"Content-Type: text/plain", contentRange(half, len(limeric))}, body: limeric[half:]},
+		}
+	}
+
+	return []multipartPart{
+		{headers: []string{`Content-Disposition: form-data; name="title"`}, body: "limeric"},
+		{headers: []string{`Content-Disposition: form-data; name="file"; filename="limeric.txt"`, "Content-Type: text/plain"}, body: limeric},
+	}
+}
+
+// malformedMultipartBoundary responds with a multipart body of 'type' (form-data, byteranges),
+// malformed according to 'mode':
+//
+//	mismatch      - parts are delimited by a boundary other than the declared one
+//	missing-close - the closing boundary is missing
+//	no-boundary   - Content-Type has no boundary parameter
+//	none          - the body is well-formed
+func malformedMultipartBoundary(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	kind := query.Get("type")
+	switch kind {
+	case "":
+		kind = "form-data"
+	case "form-data", "byteranges":
+	default:
+		badRequest(rw, fmt.Errorf("unknown type %q, expected form-data or byteranges", kind))
+		return nil
+	}
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "mismatch"
+	}
+
+	declared, actual, closed := multipartBoundary, multipartBoundary, true
+	switch mode {
+	case "none":
+	case "mismatch":
+		actual = multipartBoundary + "-other"
+	case "missing-close":
+		closed = false
+	case "no-boundary":
+		declared = ""
+	default:
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected mismatch, missing-close, no-boundary or none", mode))
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	for _, part := range limericParts(kind) {
+		writeStrs(body, "--", actual, "\r\n")
+		for _, header := range part.headers {
+			writeStrs(body, header, "\r\n")
+		}
+		writeStrs(body, "\r\n", part.body, "\r\n")
+	}
+	if closed {
+		writeStrs(body, "--", actual, "--\r\n")
+	}
+
+	contentType := "multipart/" + kind
+	if declared != "" {
+		contentType += "; boundary=" + declared
+	}
+
+	statusLine := "HTTP/1.1 200 OK\r\n"
+	if kind == "byteranges" {
+		statusLine = "HTTP/1.1 206 Partial Content\r\n"
+	}
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		statusLine,
+		"Content-Type: ", contentType, "\r\n",
+		"Content-Length: ", strconv.Itoa(body.Len()), "\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	resp.Write(body.Bytes())
+
+	slog.InfoContext(ctx, "writing malformed multipart body",
+		"mode", mode, "type", kind, "declared_boundary", declared, "actual_boundary", actual, "closed", closed)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("malformed multipart: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// multiStatus responds with 207 Multi-Status and a WebDAV-style XML body.
+// The 'resources' parameter lists href=status pairs, 'mode=malformed' breaks the XML.
+func multiStatus(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	resources := query.Get("resources")
+	if resources == "" {
+		resources = "/=200,/missing=404"
+	}
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "valid"
+	}
+	if mode != "valid" && mode != "malformed" {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected valid or malformed", mode))
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	writeStrs(body,
+		`<?xml version="1.0" encoding="utf-8"?>`, "\n",
+		`<D:multistatus xmlns:D="DAV:">`, "\n",
+	)
+
+	for _, resource := range strings.Split(resources, ",") {
+		href, statusStr, ok := strings.Cut(resource, "=")
+		status, errStatus := strconv.Atoi(statusStr)
+		if !ok || errStatus != nil || status < 100 || status > 999 {
+			badRequest(rw, fmt.Errorf("malformed resource %q, expected href=status", resource))
+			return nil
+		}
+
+		writeStrs(body, "  <D:response>\n    <D:href>")
+		if mode == "malformed" {
+			// unescaped markup in text content
+			body.WriteString(href + "<&")
+		} else {
+			_ = xml.EscapeText(body, []byte(href))
+		}
+		writeStrs(body, "</D:href>\n",
+			"    <D:status>HTTP/1.1 ", strconv.Itoa(status), " ", http.StatusText(status), "</D:status>\n",
+			"  </D:response>\n",
+		)
+	}
+
+	if mode == "valid" {
+		body.WriteString("</D:multistatus>\n")
+	}
+
+	slog.InfoContext(ctx, "writing multi-status response", "resources", resources, "mode", mode)
+
+	rw.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	rw.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	rw.WriteHeader(http.StatusMultiStatus)
+
+	if _, errWrite := rw.Write(body.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type paginatedPage struct {
+	Page  int      `json:"page"`
+	Pages int      `json:"pages"`
+	Items []string `json:"items"`
+}
+
+// pageURL points at the same badserv URL with 'page' parameter replaced.
+func pageURL(req *http.Request, page int) string {
+	u := url.URL{
+		Scheme: "http",
+		Host:   req.Host,
+		Path:   req.URL.Path,
+	}
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	query := req.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// respondWithLinkPagination responds with a limeric line per 'page' out of 'pages'
+// and Link headers pointing at first, prev, next and last pages, malformed according to 'mode':
+//
+//	none         - well-formed links
+//	no-brackets  - URLs aren't enclosed in angle brackets
+//	unterminated - the closing angle bracket is missing
+//	self-loop    - next page points at the current one
+func respondWithLinkPagination(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	pages, errPages := queryInt(query, "pages", 5)
+	if errPages == nil && pages == 0 {
+		errPages = errors.New("pages must be positive")
+	}
+	if errPages != nil {
+		badRequest(rw, errPages)
+		return nil
+	}
+
+	page, errPage := queryInt(query, "page", 1)
+	if errPage == nil && (page == 0 || page > pages) {
+		errPage = fmt.Errorf("page must be from 1 to %d", pages)
+	}
+	if errPage != nil {
+		badRequest(rw, errPage)
+		return nil
+	}
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "none"
+	}
+
+	var format func(target, rel string) string
+	switch mode {
+	case "none", "self-loop":
+		format = func(target, rel string) string { return "<" + target + `>; rel="` + rel + `"` }
+	case "no-brackets":
+		format = func(target, rel string) string { return target + `; rel="` + rel + `"` }
+	case "unterminated":
+		format = func(target, rel string) string { return "<" + target + `; rel="` + rel + `"` }
+	default:
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected none, no-brackets, unterminated or self-loop", mode))
+		return nil
+	}
+
+	links := []string{format(pageURL(req, 1), "first")}
+	if page > 1 {
+		links = append(links, format(pageURL(req, page-1), "prev"))
+	}
+	switch {
+	case mode == "self-loop":
+		links = append(links, format(pageURL(req, page), "next"))
+	case page < pages:
+		links = append(links, format(pageURL(req, page+1), "next"))
+	}
+	links = append(links, format(pageURL(req, pages), "last"))
+
+	slog.InfoContext(ctx, "writing pagination links", "page", page, "pages", pages, "mode", mode, "links", links)
+
+	lines := strings.Split(strings.TrimSpace(limeric), "\n")
+	rw.Header().Set("Link", strings.Join(links, ", "))
+
+	return writeJSON(rw, paginatedPage{
+		Page:  page,
+		Pages: pages,
+		Items: []string{lines[(page-1)%len(lines)]},
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryInt parses an integer query parameter, returning def if the parameter is absent.
+func queryInt(query url.Values, name string, def int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	if n < 0 {
+		return 0, fmt.Errorf("parsing %s: must not be negative", name)
+	}
+
+	return n, nil
+}
+
+// queryDuration parses a duration query parameter like "1.5s", returning def if the parameter is absent.
+func queryDuration(query url.Values, name string, def time.Duration) (time.Duration, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("parsing %s: must not be negative", name)
+	}
+
+	return d, nil
+}
+
+// queryBool parses a boolean query parameter like "true" or "1", returning def if the parameter is absent.
+func queryBool(query url.Values, name string, def bool) (bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	return b, nil
+}
+
+type kvPair struct {
+	key, value string
+}
+
+// parseKVPairs parses comma-separated k=v pairs from the query parameter name.
+// Keys and values are checked to be safe to put into a header.
+func parseKVPairs(name, s string) ([]kvPair, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var pairs []kvPair
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s: malformed pair %q", name, field)
+		}
+
+		if strings.ContainsAny(key, " \t\r\n:") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%s: invalid characters in pair %q", name, field)
+		}
+
+		pairs = append(pairs, kvPair{key: key, value: value})
+	}
+
+	return pairs, nil
+}
+
+func badRequest(rw http.ResponseWriter, err error) {
+	http.Error(rw, "bad request: "+err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// writePIDFile writes the process ID to the file, replacing its content.
+func writePIDFile(file string) error {
+	return os.WriteFile(file, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// signalReady writes a newline to the file descriptor inherited from the parent process and closes it,
+// so the parent can block on reading it until the server is ready to accept connections.
+func signalReady(fd int) error {
+	ready := os.NewFile(uintptr(fd), "ready-fd")
+	if ready == nil {
+		return fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer ready.Close()
+
+	if _, err := ready.Write([]byte("\n")); err != nil {
+		return fmt.Errorf("writing to file descriptor %d: %w", fd, err)
+	}
+
+	return nil
+}
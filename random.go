@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockedRand is a math/rand source safe for concurrent use, seeded by -seed flag.
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rnd.Float64()
+}
+
+func (r *lockedRand) ExpFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rnd.ExpFloat64()
+}
+
+func (r *lockedRand) NormFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rnd.NormFloat64()
+}
+
+// delayDistribution samples a response delay.
+type delayDistribution func(rnd *lockedRand) time.Duration
+
+// parseDelayDistribution parses a distribution in "name:param,param" form:
+//
+//	constant:100ms
+//	uniform:10ms,200ms
+//	exponential:50ms     - mean delay
+//	lognormal:50ms,0.5   - median delay and sigma
+func parseDelayDistribution(s string) (delayDistribution, error) {
+	name, paramsStr, _ := strings.Cut(s, ":")
+	params := strings.Split(paramsStr, ",")
+
+	parseParams := func(n int) ([]time.Duration, error) {
+		if len(params) != n {
+			return nil, fmt.Errorf("%s distribution expects %d parameter(s), got %d", name, n, len(params))
+		}
+
+		durations := make([]time.Duration, n)
+		for i, param := range params {
+			d, err := time.ParseDuration(param)
+			if err != nil {
+				return nil, fmt.Errorf("%s distribution: %w", name, err)
+			}
+			if d < 0 {
+				return nil, fmt.Errorf("%s distribution: negative delay %s", name, d)
+			}
+			durations[i] = d
+		}
+
+		return durations, nil
+	}
+
+	switch name {
+	case "constant":
+		durations, err := parseParams(1)
+		if err != nil {
+			return nil, err
+		}
+		return func(*lockedRand) time.Duration {
+			return durations[0]
+		}, nil
+	case "uniform":
+		durations, err := parseParams(2)
+		if err != nil {
+			return nil, err
+		}
+		low, high := durations[0], durations[1]
+		if low > high {
+			return nil, errors.New("uniform distribution: min exceeds max")
+		}
+		return func(rnd *lockedRand) time.Duration {
+			return low + time.Duration(rnd.Float64()*float64(high-low))
+		}, nil
+	case "exponential":
+		durations, err := parseParams(1)
+		if err != nil {
+			return nil, err
+		}
+		mean := durations[0]
+		return func(rnd *lockedRand) time.Duration {
+			return time.Duration(rnd.ExpFloat64() * float64(mean))
+		}, nil
+	case "lognormal":
+		if len(params) != 2 {
+			return nil, fmt.Errorf("lognormal distribution expects 2 parameters, got %d", len(params))
+		}
+		median, errMedian := time.ParseDuration(params[0])
+		if errMedian != nil || median <= 0 {
+			return nil, fmt.Errorf("lognormal distribution: malformed median %q", params[0])
+		}
+		sigma, errSigma := strconv.ParseFloat(params[1], 64)
+		if errSigma != nil || sigma < 0 {
+			return nil, fmt.Errorf("lognormal distribution: malformed sigma %q", params[1])
+		}
+		return func(rnd *lockedRand) time.Duration {
+			return time.Duration(float64(median) * math.Exp(sigma*rnd.NormFloat64()))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q, expected constant, uniform, exponential or lognormal", name)
+	}
+}
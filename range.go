@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseByteRange parses the first range of a Range header for a content of given size.
+// It returns inclusive start and end offsets.
+func parseByteRange(header string, size int) (start, end int, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported range unit in %q", header)
+	}
+
+	spec, _, _ = strings.Cut(spec, ",")
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("malformed range %q", spec)
+	}
+
+	switch {
+	case first == "":
+		// suffix range: last N bytes
+		n, errN := strconv.Atoi(last)
+		if errN != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("malformed suffix range %q", spec)
+		}
+		start, end = max(size-n, 0), size-1
+	default:
+		var errStart, errEnd error
+		start, errStart = strconv.Atoi(first)
+		end = size - 1
+		if last != "" {
+			end, errEnd = strconv.Atoi(last)
+		}
+		if errStart != nil || errEnd != nil || start > end {
+			return 0, 0, fmt.Errorf("malformed range %q", spec)
+		}
+		end = min(end, size-1)
+	}
+
+	if start >= size {
+		return 0, 0, errors.New("range is not satisfiable")
+	}
+
+	return start, end, nil
+}
+
+// serveRangeWithGaps responds to a Range request with an accurate 206 header,
+// but sends 'shortfall' bytes less than the range and closes the connection.
+// Requests without Range header get the whole limeric.
+func serveRangeWithGaps(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	shortfall, errShortfall := queryInt(req.URL.Query(), "shortfall", 10)
+	if errShortfall != nil {
+		badRequest(rw, errShortfall)
+		return nil
+	}
+
+	rangeHeader := req.Header.Get("Range")
+	if rangeHeader == "" {
+		http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
+		return nil
+	}
+
+	start, end, errRange := parseByteRange(rangeHeader, len(limeric))
+	if errRange != nil {
+		rw.Header().Set("Content-Range", "bytes */"+strconv.Itoa(len(limeric)))
+		http.Error(rw, errRange.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+
+	requested := end - start + 1
+	delivered := max(requested-shortfall, 0)
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 206 Partial Content\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Range: bytes ", strconv.Itoa(start), "-", strconv.Itoa(end), "/", strconv.Itoa(len(limeric)), "\r\n",
+		"Content-Length: ", strconv.Itoa(requested), "\r\n",
+		"Connection: close\r\n\r\n",
+		limeric[start:start+delivered],
+	)
+
+	slog.InfoContext(ctx, "serving range with gap", "range", rangeHeader, "requested", requested, "delivered", delivered)
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("range with gap: %w", err)
+	}
+
+	return nil
+}
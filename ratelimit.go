@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slidingWindows keep request times per client for respond-based-on-request-count-window action.
+type slidingWindows struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+}
+
+// allow records a request of the client at now, unless it already made 'limit' requests
+// within the window. It returns the number of requests in the window and,
+// if the request isn't allowed, how long to wait for the oldest of them to expire.
+func (w *slidingWindows) allow(client string, limit int, window time.Duration, now time.Time) (int, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.requests == nil {
+		w.requests = map[string][]time.Time{}
+	}
+
+	times := w.requests[client]
+	expired := 0
+	for expired < len(times) && now.Sub(times[expired]) >= window {
+		expired++
+	}
+	times = times[expired:]
+
+	if len(times) >= limit {
+		w.requests[client] = times
+		return len(times), times[0].Add(window).Sub(now)
+	}
+
+	w.requests[client] = append(times, now)
+
+	return len(times) + 1, 0
+}
+
+// respondByRequestCountWindow serves the limeric to at most 'limit' requests per 'window'
+// from each client, identified by 'token' parameter or IP, and responds with 429 to the rest.
+func (srv *service) respondByRequestCountWindow(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	limit, errLimit := queryInt(query, "limit", 5)
+	if errLimit == nil && limit == 0 {
+		errLimit = errors.New("limit must be positive")
+	}
+	if errLimit != nil {
+		badRequest(rw, errLimit)
+		return nil
+	}
+
+	window, errWindow := queryDuration(query, "window", 10*time.Second)
+	if errWindow == nil && window == 0 {
+		errWindow = errors.New("window must be positive")
+	}
+	if errWindow != nil {
+		badRequest(rw, errWindow)
+		return nil
+	}
+
+	client := query.Get("token")
+	if client == "" {
+		client, _, _ = net.SplitHostPort(req.RemoteAddr)
+	}
+
+	// limiters with different settings don't share windows
+	key := strings.Join([]string{client, strconv.Itoa(limit), window.String()}, "|")
+	count, retryAfter := srv.windows.allow(key, limit, window, time.Now())
+
+	if retryAfter > 0 {
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		slog.InfoContext(ctx, "request count window exceeded",
+			"client", client, "count", count, "limit", limit, "window", window, "retry_after", retryAfter)
+
+		rw.Header().Set("Retry-After", strconv.Itoa(seconds))
+		http.Error(rw, "too many requests", http.StatusTooManyRequests)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "request allowed within count window",
+		"client", client, "count", count, "limit", limit, "window", window)
+
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit-count))
+	_, err := rw.Write([]byte(limeric))
+
+	return err
+}
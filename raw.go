@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// writeRaw hijacks the connection, writes data as is and closes the connection.
+// It's used by actions which need full control over the response framing.
+func writeRaw(rw http.ResponseWriter, data []byte) error {
+	controller := http.NewResponseController(rw)
+
+	conn, w, errHijack := controller.Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	if _, errWrite := w.Write(data); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("flushing response: %w", errFlush)
+	}
+
+	return nil
+}
+
+// waitClosed blocks until the client closes the hijacked connection or ctx is done.
+// Anything the client sends meanwhile is discarded.
+func waitClosed(ctx context.Context, r *bufio.Reader) {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_, _ = io.Copy(io.Discard, r)
+	}()
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+	}
+}
+
+// closeAware returns a context canceled once the client closes the hijacked connection.
+// net/http stops watching the connection after hijack, so the request context isn't canceled by itself.
+// Anything the client sends meanwhile is discarded.
+func closeAware(ctx context.Context, r *bufio.Reader) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		_, _ = io.Copy(io.Discard, r)
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type cookieInfo struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// reflectCookies responds with the request cookies as JSON, with values replaced if 'redact' is set.
+func reflectCookies(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	redact, errRedact := queryBool(req.URL.Query(), "redact", false)
+	if errRedact != nil {
+		badRequest(rw, errRedact)
+		return nil
+	}
+
+	cookies := []cookieInfo{}
+	names := []string{}
+	for _, cookie := range req.Cookies() {
+		value := cookie.Value
+		if redact {
+			value = "REDACTED"
+		}
+		cookies = append(cookies, cookieInfo{Name: cookie.Name, Value: value})
+		names = append(names, cookie.Name)
+	}
+
+	slog.InfoContext(ctx, "reflecting cookies", "names", names, "redact", redact)
+
+	return writeJSON(rw, cookies)
+}
+
+// reflectMethod responds with the request method.
+// If 'allow' lists methods, others get 405 with the list in Allow header.
+func reflectMethod(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	if query.Has("allow") {
+		allowed := strings.Split(query.Get("allow"), ",")
+		for i := range allowed {
+			allowed[i] = strings.ToUpper(strings.TrimSpace(allowed[i]))
+		}
+
+		if !slices.Contains(allowed, req.Method) {
+			slog.InfoContext(ctx, "reflecting method", "method", req.Method, "mode", "method-not-allowed", "allow", allowed)
+
+			rw.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	}
+
+	slog.InfoContext(ctx, "reflecting method", "method", req.Method, "mode", "reflect")
+
+	rw.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(rw, req.Method+"\n")
+}
+
+// reflectRawRequestLine responds with the request line as received.
+func reflectRawRequestLine(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	requestLine := req.Method + " " + req.RequestURI + " " + req.Proto
+
+	slog.InfoContext(ctx, "reflecting request line", "request_line", requestLine)
+
+	rw.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(rw, requestLine+"\n")
+}
+
+type requestSize struct {
+	HeaderBytes   int   `json:"header_bytes"`
+	BodyBytes     int64 `json:"body_bytes"`
+	TotalBytes    int64 `json:"total_bytes"`
+	ContentLength int64 `json:"content_length"`
+}
+
+// reflectRequestSize reads the request body and responds with request size stats as JSON.
+// The header size is reconstructed from the parsed request, so it ignores original whitespace.
+func reflectRequestSize(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	headerBytes := len(req.Method+" "+req.RequestURI+" "+req.Proto+"\r\n") +
+		len("Host: "+req.Host+"\r\n") +
+		len("\r\n")
+	for name, values := range req.Header {
+		for _, value := range values {
+			headerBytes += len(name + ": " + value + "\r\n")
+		}
+	}
+
+	bodyBytes, errRead := io.Copy(io.Discard, req.Body)
+	if errRead != nil {
+		return fmt.Errorf("reading body: %w", errRead)
+	}
+
+	size := requestSize{
+		HeaderBytes:   headerBytes,
+		BodyBytes:     bodyBytes,
+		TotalBytes:    int64(headerBytes) + bodyBytes,
+		ContentLength: req.ContentLength,
+	}
+
+	slog.InfoContext(ctx, "reflecting request size",
+		"header_bytes", size.HeaderBytes, "body_bytes", size.BodyBytes, "total_bytes", size.TotalBytes)
+
+	return writeJSON(rw, size)
+}
+
+type authInfo struct {
+	Present  bool              `json:"present"`
+	Scheme   string            `json:"scheme,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Secret   string            `json:"secret,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// digestSecretParams are not reflected by reflect-auth.
+var digestSecretParams = map[string]bool{
+	"response": true,
+	"cnonce":   true,
+}
+
+// reflectAuth describes the Authorization header as JSON, with passwords and tokens redacted.
+func reflectAuth(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	info := authInfo{}
+	header := req.Header.Get("Authorization")
+	if header != "" {
+		info.Present = true
+
+		scheme, credentials, _ := strings.Cut(header, " ")
+		info.Scheme = scheme
+		credentials = strings.TrimSpace(credentials)
+
+		switch strings.ToLower(scheme) {
+		case "basic":
+			username, _, ok := req.BasicAuth()
+			if ok {
+				info.Username = username
+				info.Secret = "redacted password"
+			} else {
+				info.Error = "malformed basic credentials"
+			}
+		case "bearer":
+			if credentials != "" {
+				info.Secret = fmt.Sprintf("redacted token of %d bytes", len(credentials))
+			} else {
+				info.Error = "empty bearer token"
+			}
+		case "digest":
+			info.Params = map[string]string{}
+			for _, param := range strings.Split(credentials, ",") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				name = strings.ToLower(name)
+				if name == "" {
+					continue
+				}
+				if digestSecretParams[name] {
+					value = "redacted"
+				}
+				info.Params[name] = strings.Trim(value, `"`)
+			}
+			info.Username = info.Params["username"]
+		default:
+			info.Error = "unsupported scheme"
+		}
+	}
+
+	slog.InfoContext(ctx, "reflecting authorization", "present", info.Present, "scheme", info.Scheme)
+
+	return writeJSON(rw, info)
+}
+
+type forwardedChain struct {
+	RemoteAddr      string              `json:"remote_addr"`
+	XForwardedFor   []string            `json:"x_forwarded_for"`
+	XForwardedProto []string            `json:"x_forwarded_proto"`
+	XForwardedHost  []string            `json:"x_forwarded_host"`
+	Forwarded       []map[string]string `json:"forwarded"`
+	Via             []string            `json:"via"`
+}
+
+// splitQuoted splits s by sep, ignoring separators inside quoted strings.
+// Parts are trimmed, but otherwise kept verbatim, quotes included.
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// headerList joins all values of the comma-separated header in order of appearance.
+func headerList(header http.Header, name string) []string {
+	list := []string{}
+	for _, value := range header.Values(name) {
+		list = append(list, splitQuoted(value, ',')...)
+	}
+
+	return list
+}
+
+// reflectForwardedChain responds with forwarding headers appended by proxies as JSON.
+// Values are reported verbatim in order of appearance, neither validated nor trusted.
+func reflectForwardedChain(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	chain := forwardedChain{
+		RemoteAddr:      req.RemoteAddr,
+		XForwardedFor:   headerList(req.Header, "X-Forwarded-For"),
+		XForwardedProto: headerList(req.Header, "X-Forwarded-Proto"),
+		XForwardedHost:  headerList(req.Header, "X-Forwarded-Host"),
+		Forwarded:       []map[string]string{},
+		Via:             headerList(req.Header, "Via"),
+	}
+
+	for _, element := range headerList(req.Header, "Forwarded") {
+		pairs := map[string]string{}
+		for _, pair := range splitQuoted(element, ';') {
+			if pair == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(pair, "=")
+			pairs[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+		chain.Forwarded = append(chain.Forwarded, pairs)
+	}
+
+	slog.InfoContext(ctx, "reflecting forwarded chain",
+		"x_forwarded_for", chain.XForwardedFor, "forwarded", len(chain.Forwarded), "via", chain.Via)
+
+	return writeJSON(rw, chain)
+}
+
+type pathSegment struct {
+	Raw     string            `json:"raw"`
+	Decoded string            `json:"decoded"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+type decodedPath struct {
+	RequestURI   string        `json:"request_uri"`
+	EscapedPath  string        `json:"escaped_path"`
+	Path         string        `json:"path"`
+	EncodedSlash bool          `json:"encoded_slash"`
+	Segments     []pathSegment `json:"segments"`
+}
+
+// reflectDecodedPath responds with the raw and decoded forms of the request path as JSON.
+// Segments are decoded one by one, so encoded slashes stay inside their segments,
+// and ';k=v' path parameters are split out of them.
+func reflectDecodedPath(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	escaped := req.URL.EscapedPath()
+	info := decodedPath{
+		RequestURI:   req.RequestURI,
+		EscapedPath:  escaped,
+		Path:         req.URL.Path,
+		EncodedSlash: strings.Contains(strings.ToUpper(escaped), "%2F"),
+		Segments:     []pathSegment{},
+	}
+
+	for _, raw := range strings.Split(strings.TrimPrefix(escaped, "/"), "/") {
+		segment := pathSegment{Raw: raw}
+
+		name, params, _ := strings.Cut(raw, ";")
+		if params != "" {
+			segment.Params = map[string]string{}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(param, "=")
+				segment.Params[key] = value
+			}
+		}
+
+		// the server rejects malformed escapes before handling the request
+		segment.Decoded, _ = url.PathUnescape(name)
+
+		info.Segments = append(info.Segments, segment)
+	}
+
+	slog.InfoContext(ctx, "reflecting path", "escaped_path", info.EscapedPath, "path", info.Path)
+
+	return writeJSON(rw, info)
+}
+
+type conditionsInfo struct {
+	IfMatch           string `json:"if_match,omitempty"`
+	IfNoneMatch       string `json:"if_none_match,omitempty"`
+	IfModifiedSince   string `json:"if_modified_since,omitempty"`
+	IfUnmodifiedSince string `json:"if_unmodified_since,omitempty"`
+	IfRange           string `json:"if_range,omitempty"`
+	ETag              string `json:"etag"`
+	LastModified      string `json:"last_modified"`
+	Status            int    `json:"status"`
+	Reason            string `json:"reason"`
+	RangeHonored      *bool  `json:"range_honored,omitempty"`
+}
+
+// etagMatches reports whether the comma-separated list of entity tags matches etag,
+// using weak comparison unless strong is set.
+func etagMatches(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		switch {
+		case candidate == "*":
+			return true
+		case strong && (strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/")):
+			continue
+		case strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/"):
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluateConditions decides the response status for conditional request headers
+// following the order of RFC 9110, section 13.2.2.
+func evaluateConditions(req *http.Request, etag string, lastModified time.Time) (int, string) {
+	ifMatch, ifNoneMatch := req.Header.Get("If-Match"), req.Header.Get("If-None-Match")
+	readOnly := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if ifMatch != "" {
+		if !etagMatches(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed, "If-Match doesn't match the ETag"
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed, "modified after If-Unmodified-Since"
+		}
+	}
+
+	if ifNoneMatch != "" {
+		if etagMatches(ifNoneMatch, etag, false) {
+			if readOnly {
+				return http.StatusNotModified, "If-None-Match matches the ETag"
+			}
+			return http.StatusPreconditionFailed, "If-None-Match matches the ETag of unsafe request"
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && readOnly {
+		if !lastModified.After(since) {
+			return http.StatusNotModified, "not modified since If-Modified-Since"
+		}
+	}
+
+	return http.StatusOK, "all conditions passed"
+}
+
+// reflectIfConditions responds with the conditional request headers as JSON,
+// along with the decision made against 'etag' and 'last-modified'.
+// With 'mode=evaluate' the decision is applied to the response status.
+func reflectIfConditions(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	mode := query.Get("mode")
+	if mode != "" && mode != "reflect" && mode != "evaluate" {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected reflect or evaluate", mode))
+		return nil
+	}
+
+	etag := query.Get("etag")
+	if etag == "" {
+		etag = `"limeric"`
+	}
+	if strings.ContainsAny(etag, "\r\n") {
+		badRequest(rw, fmt.Errorf("malformed etag %q", etag))
+		return nil
+	}
+
+	lastModified := time.Date(2023, time.November, 25, 0, 0, 0, 0, time.UTC)
+	if value := query.Get("last-modified"); value != "" {
+		parsed, err := http.ParseTime(value)
+		if err != nil {
+			badRequest(rw, fmt.Errorf("parsing last-modified: %w", err))
+			return nil
+		}
+		lastModified = parsed
+	}
+
+	info := conditionsInfo{
+		IfMatch:           req.Header.Get("If-Match"),
+		IfNoneMatch:       req.Header.Get("If-None-Match"),
+		IfModifiedSince:   req.Header.Get("If-Modified-Since"),
+		IfUnmodifiedSince: req.Header.Get("If-Unmodified-Since"),
+		IfRange:           req.Header.Get("If-Range"),
+		ETag:              etag,
+		LastModified:      lastModified.Format(http.TimeFormat),
+	}
+	info.Status, info.Reason = evaluateConditions(req, etag, lastModified)
+
+	if info.IfRange != "" && req.Header.Get("Range") != "" {
+		honored := etagMatches(info.IfRange, etag, true)
+		if since, err := http.ParseTime(info.IfRange); err == nil {
+			honored = lastModified.Equal(since)
+		}
+		info.RangeHonored = &honored
+	}
+
+	slog.InfoContext(ctx, "reflecting conditions",
+		"if_match", info.IfMatch, "if_none_match", info.IfNoneMatch,
+		"if_modified_since", info.IfModifiedSince, "if_unmodified_since", info.IfUnmodifiedSince,
+		"if_range", info.IfRange, "status", info.Status, "reason", info.Reason)
+
+	if mode != "evaluate" {
+		return writeJSON(rw, info)
+	}
+
+	rw.Header().Set("ETag", etag)
+	rw.Header().Set("Last-Modified", info.LastModified)
+	if info.Status == http.StatusNotModified {
+		rw.WriteHeader(info.Status)
+		return nil
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(info.Status)
+	if errEncode := json.NewEncoder(rw).Encode(info); errEncode != nil {
+		return fmt.Errorf("writing response: %w", errEncode)
+	}
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requireHeader responds with 400 unless the request has 'header', equal to 'value' if it's set.
+// Otherwise it serves the limeric.
+func requireHeader(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	header := query.Get("header")
+	if header == "" {
+		badRequest(rw, errors.New("header parameter is required"))
+		return
+	}
+
+	wantValue, checkValue := query.Get("value"), query.Has("value")
+	values, present := req.Header[http.CanonicalHeaderKey(header)]
+
+	matched := present
+	if present && checkValue {
+		matched = slices.Contains(values, wantValue)
+	}
+
+	slog.InfoContext(ctx, "checking required header", "header", header, "present", present, "matched", matched)
+
+	if !matched {
+		http.Error(rw, "missing or mismatched required header "+header, http.StatusBadRequest)
+		return
+	}
+
+	http.ServeContent(rw, req, "limeric.txt", time.Now(), strings.NewReader(limeric))
+}
+
+// closeOnBytesReceived reads the request body and closes the connection
+// once 'close-after' bytes are read or 'pattern' is found.
+func closeOnBytesReceived(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	pattern := []byte(query.Get("pattern"))
+	closeAfter, errCloseAfter := queryInt(query, "close-after", 0)
+	if errCloseAfter == nil && closeAfter == 0 && len(pattern) == 0 {
+		errCloseAfter = errors.New("either close-after or pattern is required")
+	}
+	if errCloseAfter != nil {
+		badRequest(rw, errCloseAfter)
+		return nil
+	}
+
+	body := bufio.NewReader(req.Body)
+	window := make([]byte, 0, len(pattern))
+	received := 0
+
+	for {
+		b, errRead := body.ReadByte()
+		if errors.Is(errRead, io.EOF) {
+			break
+		}
+		if errRead != nil {
+			return fmt.Errorf("reading body: %w", errRead)
+		}
+		received++
+
+		if len(pattern) > 0 {
+			if len(window) == len(pattern) {
+				window = append(window[:0], window[1:]...)
+			}
+			window = append(window, b)
+		}
+
+		var trigger string
+		switch {
+		case closeAfter > 0 && received >= closeAfter:
+			trigger = "close-after"
+		case len(pattern) > 0 && bytes.Equal(window, pattern):
+			trigger = "pattern"
+		default:
+			continue
+		}
+
+		slog.InfoContext(ctx, "closing connection mid-upload", "trigger", trigger, "received", received)
+
+		return closeConn(rw)
+	}
+
+	slog.InfoContext(ctx, "request body received without trigger", "received", received)
+
+	fmt.Fprintf(rw, "received %d bytes\n", received)
+
+	return nil
+}
+
+// longURL redirects to the same path with the query padded to make the request URI 'length' bytes long,
+// up to http.DefaultMaxHeaderBytes.
+func longURL(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	length, errLength := queryInt(req.URL.Query(), "length", 8192)
+	// longer URLs wouldn't fit into the server's own request header limit anyway
+	if errLength == nil && length > http.DefaultMaxHeaderBytes {
+		errLength = fmt.Errorf("length must not exceed %d", http.DefaultMaxHeaderBytes)
+	}
+	if errLength != nil {
+		badRequest(rw, errLength)
+		return
+	}
+
+	target := req.URL.EscapedPath() + "?pad="
+	if padding := length - len(target); padding > 0 {
+		target += strings.Repeat("x", padding)
+	}
+
+	slog.InfoContext(ctx, "redirecting to long URL", "length", len(target))
+
+	http.Redirect(rw, req, target, http.StatusFound)
+}
+
+// rejectCompressedRequest responds with 415 if the request body is encoded with one of 'reject' encodings.
+// Otherwise it reads the body and reports its size.
+func rejectCompressedRequest(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	reject := "gzip,x-gzip,deflate,br,zstd,compress"
+	if req.URL.Query().Has("reject") {
+		reject = req.URL.Query().Get("reject")
+	}
+	rejected := strings.Split(strings.ToLower(reject), ",")
+
+	var encodings []string
+	for _, value := range req.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encodings = append(encodings, strings.ToLower(strings.TrimSpace(encoding)))
+		}
+	}
+
+	for _, encoding := range encodings {
+		if slices.Contains(rejected, encoding) {
+			slog.InfoContext(ctx, "rejecting compressed request", "content_encoding", encodings, "rejected", true)
+
+			rw.Header().Set("Accept-Encoding", "identity")
+			http.Error(rw, "unsupported content encoding "+encoding, http.StatusUnsupportedMediaType)
+			return nil
+		}
+	}
+
+	slog.InfoContext(ctx, "accepting request", "content_encoding", encodings, "rejected", false)
+
+	received, errRead := io.Copy(io.Discard, req.Body)
+	if errRead != nil {
+		return fmt.Errorf("reading body: %w", errRead)
+	}
+
+	fmt.Fprintf(rw, "received %d bytes\n", received)
+
+	return nil
+}
+
+// respondThenReadMore sends a complete 'status' response before reading the request body.
+// With 'mode=continue' the server then reads the rest of the body, with 'mode=stop'
+// it doesn't read anything and closes the connection after 'delay'.
+func respondThenReadMore(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "stop"
+	}
+	if mode != "stop" && mode != "continue" {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected stop or continue", mode))
+		return nil
+	}
+
+	status, errStatus := queryInt(query, "status", http.StatusRequestEntityTooLarge)
+	if errStatus == nil && (status < 200 || status > 999) {
+		errStatus = errors.New("status must be in range 200..999")
+	}
+	if errStatus != nil {
+		badRequest(rw, errStatus)
+		return nil
+	}
+
+	delay, errDelay := queryDuration(query, "delay", time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	body := http.StatusText(status) + "\n"
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 ", strconv.Itoa(status), " ", http.StatusText(status), "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(len(body)), "\r\n",
+		"Connection: close\r\n\r\n",
+		body,
+	)
+	if _, errWrite := w.Write(resp.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	slog.InfoContext(ctx, "responded before reading body", "status", status, "mode", mode)
+
+	if mode == "stop" {
+		if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+			return errSleep
+		}
+		slog.InfoContext(ctx, "closing connection without reading body", "bytes_read", 0)
+		return nil
+	}
+
+	// raw bytes are read, so chunked bodies are counted with their framing
+	var rest io.Reader = w.Reader
+	if req.ContentLength >= 0 {
+		rest = io.LimitReader(w.Reader, req.ContentLength)
+	}
+
+	read, errRead := io.Copy(io.Discard, rest)
+
+	slog.InfoContext(ctx, "read body after responding", "bytes_read", read, "content_length", req.ContentLength)
+
+	if errRead != nil {
+		return fmt.Errorf("reading body: %w", errRead)
+	}
+
+	return nil
+}
+
+type multipartPartInfo struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+// multipartFormEcho reads multipart/form-data request body part by part
+// and responds with a JSON summary of the parts, discarding their contents.
+// With 'mode=mis-parse' the body is read with a wrong boundary and rejected with 400.
+func multipartFormEcho(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	mode := req.URL.Query().Get("mode")
+	if mode != "" && mode != "parse" && mode != "mis-parse" {
+		badRequest(rw, fmt.Errorf("unknown mode %q, expected parse or mis-parse", mode))
+		return nil
+	}
+
+	mediaType, params, errMediaType := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if errMediaType != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		badRequest(rw, errors.New("multipart/form-data body with boundary is expected"))
+		return nil
+	}
+
+	boundary := params["boundary"]
+	if mode == "mis-parse" {
+		// a boundary the client never sent, so no parts are found
+		boundary += "-mis"
+	}
+
+	slog.InfoContext(ctx, "reading multipart body", "mode", mode, "boundary", boundary)
+
+	parts := []multipartPartInfo{}
+	reader := multipart.NewReader(req.Body, boundary)
+	for {
+		part, errPart := reader.NextPart()
+		if errors.Is(errPart, io.EOF) {
+			break
+		}
+		if errPart != nil {
+			slog.InfoContext(ctx, "rejecting multipart body", "parts", len(parts), "error", errPart)
+			badRequest(rw, fmt.Errorf("part %d: %w", len(parts), errPart))
+			return nil
+		}
+
+		size, errRead := io.Copy(io.Discard, part)
+		if errRead != nil {
+			return fmt.Errorf("reading part %d: %w", len(parts), errRead)
+		}
+
+		info := multipartPartInfo{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        size,
+		}
+		parts = append(parts, info)
+
+		slog.InfoContext(ctx, "multipart part received",
+			"name", info.Name, "filename", info.Filename, "content_type", info.ContentType, "size", info.Size)
+	}
+
+	if len(parts) == 0 {
+		slog.InfoContext(ctx, "rejecting multipart body without parts")
+		badRequest(rw, errors.New("no multipart parts found"))
+		return nil
+	}
+
+	return writeJSON(rw, parts)
+}
+
+type expectInfo struct {
+	Expect        string `json:"expect"`
+	Mode          string `json:"mode"`
+	SentContinue  bool   `json:"sent_continue"`
+	Waited        string `json:"waited"`
+	BodyRead      bool   `json:"body_read"`
+	BodyBytes     int64  `json:"body_bytes"`
+	BodyReadAfter string `json:"body_read_after,omitempty"`
+}
+
+// reflectExpectHeader handles the Expect header according to 'continue' mode and
+// reports how the exchange went as JSON:
+//
+//	send   - read the body right away, 100 Continue is sent by the server on the first read
+//	delay  - wait for 'delay' before reading the body
+//	skip   - respond without reading the body and sending 100 Continue
+//	reject - respond with 417 Expectation Failed without reading the body
+func reflectExpectHeader(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+	start := time.Now()
+
+	info := expectInfo{
+		Expect: req.Header.Get("Expect"),
+		Mode:   query.Get("continue"),
+	}
+	if info.Mode == "" {
+		info.Mode = "send"
+	}
+
+	delay, errDelay := queryDuration(query, "delay", time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	expectsContinue := strings.EqualFold(info.Expect, "100-continue")
+	status := http.StatusOK
+
+	switch info.Mode {
+	case "send":
+	case "delay":
+		if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+			return nil
+		}
+	case "skip":
+	case "reject":
+		status = http.StatusExpectationFailed
+	default:
+		badRequest(rw, fmt.Errorf("unknown continue mode %q, expected send, delay, skip or reject", info.Mode))
+		return nil
+	}
+	info.Waited = time.Since(start).String()
+
+	if info.Mode == "send" || info.Mode == "delay" {
+		bodyBytes, errRead := io.Copy(io.Discard, req.Body)
+		if errRead != nil {
+			return fmt.Errorf("reading request body: %w", errRead)
+		}
+
+		info.BodyRead = true
+		info.BodyBytes = bodyBytes
+		info.BodyReadAfter = time.Since(start).String()
+		info.SentContinue = expectsContinue
+	}
+
+	slog.InfoContext(ctx, "handled expect header",
+		"expect", info.Expect, "mode", info.Mode, "sent_continue", info.SentContinue,
+		"waited", info.Waited, "body_read", info.BodyRead, "body_bytes", info.BodyBytes)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if errEncode := json.NewEncoder(rw).Encode(info); errEncode != nil {
+		return fmt.Errorf("writing response: %w", errEncode)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReflectExpectHeaderNoInterimContinue(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	for mode, status := range map[string]string{
+		"skip":   "HTTP/1.1 200 ",
+		"reject": "HTTP/1.1 417 ",
+	} {
+		t.Run(mode, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+			if err != nil {
+				t.Fatalf("dialing: %v", err)
+			}
+			defer conn.Close()
+
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			// the body is never sent: the client waits for 100 Continue as curl does
+			_, err = conn.Write([]byte("POST /?action=reflect-expect-header&continue=" + mode + " HTTP/1.1\r\n" +
+				"Host: badserv\r\n" +
+				"Content-Length: 5\r\n" +
+				"Expect: 100-continue\r\n\r\n"))
+			if err != nil {
+				t.Fatalf("writing request: %v", err)
+			}
+
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading status line: %v", err)
+			}
+			if !strings.HasPrefix(line, status) {
+				t.Errorf("status line %q, want %q", line, status)
+			}
+		})
+	}
+}
+
+func TestLongURLLimit(t *testing.T) {
+	ts := newTestServer(t, &service{})
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(ts.URL + "/?action=long-url&length=100")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || len(resp.Header.Get("Location")) != 100 {
+		t.Errorf("status %d, location of %d bytes, want 302 to 100 bytes", resp.StatusCode, len(resp.Header.Get("Location")))
+	}
+
+	if resp := get(t, ts.URL+"/?action=long-url&length=1000000000000"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("huge length: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestRejectCompressedRequestNoInterimContinue(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// the body is never sent: it must be rejected unread
+	_, err = conn.Write([]byte("POST /?action=reject-compressed-request HTTP/1.1\r\n" +
+		"Host: badserv\r\n" +
+		"Content-Encoding: gzip\r\n" +
+		"Content-Length: 5\r\n" +
+		"Expect: 100-continue\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading status line: %v", err)
+	}
+	if !strings.HasPrefix(line, "HTTP/1.1 415 ") {
+		t.Errorf("status line %q, want 415", line)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sequenceCounters track the position in each status sequence, keyed by the sequence itself,
+// so clients using different sequences don't interfere.
+type sequenceCounters struct {
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// next returns the index of the next sequence step, wrapping around its length.
+func (c *sequenceCounters) next(key string, length int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.served == nil {
+		c.served = map[string]int{}
+	}
+
+	i := c.served[key] % length
+	c.served[key]++
+
+	return i
+}
+
+// parseStatusSequence parses a comma-separated list of HTTP status codes.
+func parseStatusSequence(s string) ([]int, error) {
+	var codes []int
+	for _, field := range strings.Split(s, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("malformed status code %q in sequence", field)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// jitteredStatusSequence responds to successive requests with codes from 'sequence',
+// each after a random delay between 'min-delay' and 'max-delay'.
+func (srv *service) jitteredStatusSequence(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	sequenceStr := query.Get("sequence")
+	if sequenceStr == "" {
+		sequenceStr = "503,503,200"
+	}
+
+	sequence, errSequence := parseStatusSequence(sequenceStr)
+	if errSequence != nil {
+		badRequest(rw, errSequence)
+		return nil
+	}
+
+	minDelay, errMin := queryDuration(query, "min-delay", 0)
+	if errMin != nil {
+		badRequest(rw, errMin)
+		return nil
+	}
+
+	maxDelay, errMax := queryDuration(query, "max-delay", time.Second)
+	if errMax == nil && maxDelay < minDelay {
+		errMax = errors.New("max-delay must not be less than min-delay")
+	}
+	if errMax != nil {
+		badRequest(rw, errMax)
+		return nil
+	}
+
+	i := srv.sequences.next(sequenceStr, len(sequence))
+	code := sequence[i]
+	delay := minDelay + time.Duration(srv.rnd.Float64()*float64(maxDelay-minDelay))
+
+	slog.InfoContext(ctx, "responding from status sequence", "step", i, "code", code, "delay", delay)
+
+	if errSleep := sleepCtx(ctx, delay); errSleep != nil {
+		return nil
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(code)
+	_, err := fmt.Fprintf(rw, "%d %s: step %d of %d after %s\n", code, http.StatusText(code), i+1, len(sequence), delay)
+
+	return err
+}
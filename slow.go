@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// queryRate parses the 'rate' query parameter as a drip interval.
+// The rate is measured in byte/s, def is used if the parameter is absent.
+func queryRate(query url.Values, def int) (time.Duration, error) {
+	rate, err := queryInt(query, "rate", def)
+	if err != nil {
+		return 0, err
+	}
+
+	if rate == 0 {
+		return 0, errors.New("rate must be positive")
+	}
+
+	return time.Second / time.Duration(rate), nil
+}
+
+// sleepCtx waits for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drip writes data byte by byte to w, waiting for interval before each byte.
+// It returns the number of bytes written.
+func drip(ctx context.Context, w *bufio.Writer, data []byte, interval time.Duration) (int, error) {
+	return dripGenerated(ctx, w, len(data), func(i int) byte { return data[i] }, interval)
+}
+
+// dripGenerated is drip for 'size' bytes, the i-th of them is gen(i),
+// so the body size isn't limited by memory.
+func dripGenerated(ctx context.Context, w *bufio.Writer, size int, gen func(i int) byte, interval time.Duration) (int, error) {
+	for i := 0; i < size; i++ {
+		if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+			return i, errSleep
+		}
+
+		if errWrite := w.WriteByte(gen(i)); errWrite != nil {
+			return i, fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return i, fmt.Errorf("writing response: %w", errFlush)
+		}
+	}
+
+	return size, nil
+}
+
+// slowWriteClientSpeed drips the limeric response and logs how long each write blocks.
+// Writes blocking longer than the drip interval indicate a slowly reading client,
+// in that case the server doesn't add its own delay on top.
+func slowWriteClientSpeed(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	interval, errRate := queryRate(req.URL.Query(), 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	slog.InfoContext(ctx, "writing slow response", "interval", interval)
+
+	slowClient := false
+	for i, b := range limericResponse(req) {
+		start := time.Now()
+		if _, errWrite := w.Write([]byte{b}); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+		blocked := time.Since(start)
+
+		slog.DebugContext(ctx, "write observed", "offset", i, "blocked", blocked)
+
+		if isSlow := blocked >= interval; isSlow != slowClient {
+			slowClient = isSlow
+			if isSlow {
+				slog.InfoContext(ctx, "client appears to read slowly", "offset", i, "blocked", blocked)
+			} else {
+				slog.InfoContext(ctx, "client appears to read quickly", "offset", i, "blocked", blocked)
+			}
+		}
+
+		if errSleep := sleepCtx(ctx, interval-blocked); errSleep != nil {
+			return errSleep
+		}
+	}
+
+	return nil
+}
+
+// slowWritePercentThenHang drips 'percent' of the body at 'rate' byte/s and then hangs
+// until the client closes connection.
+func slowWritePercentThenHang(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	percent, errPercent := queryInt(query, "percent", 50)
+	if errPercent == nil && percent > 100 {
+		errPercent = errors.New("percent must not exceed 100")
+	}
+	if errPercent != nil {
+		badRequest(rw, errPercent)
+		return nil
+	}
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	resp := limericResponse(req)
+	headerSize := bytes.Index(resp, []byte("\r\n\r\n")) + 4
+	header, body := resp[:headerSize], resp[headerSize:]
+
+	if _, errWrite := w.Write(header); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	part := body[:len(body)*percent/100]
+	slog.InfoContext(ctx, "writing slow partial response", "percent", percent, "bytes", len(part), "interval", interval)
+
+	if _, errDrip := drip(ctx, w.Writer, part, interval); errDrip != nil {
+		return errDrip
+	}
+
+	slog.InfoContext(ctx, "hanging after partial response", "percent", percent, "sent", len(part), "total", len(body))
+
+	waitClosed(ctx, w.Reader)
+
+	return nil
+}
+
+// slowWriteContentLengthUpdate declares 'content-length' bytes, drips only 'sent' of them
+// at 'rate' byte/s and holds the connection open without closing it,
+// so the client waits for the promised rest of the body forever.
+func slowWriteContentLengthUpdate(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	declared, errDeclared := queryInt(query, "content-length", 2*len(limeric))
+	if errDeclared != nil {
+		badRequest(rw, errDeclared)
+		return nil
+	}
+
+	sent, errSent := queryInt(query, "sent", declared/2)
+	if errSent == nil && sent >= declared {
+		errSent = errors.New("sent must be less than content-length")
+	}
+	if errSent != nil {
+		badRequest(rw, errSent)
+		return nil
+	}
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	header := &bytes.Buffer{}
+	writeStrs(header,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain\r\n",
+		"Content-Length: ", strconv.Itoa(declared), "\r\n\r\n",
+	)
+
+	if _, errWrite := w.Write(header.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	slog.InfoContext(ctx, "writing slow short response", "declared", declared, "sent", sent, "interval", interval)
+
+	body := func(i int) byte { return limeric[i%len(limeric)] }
+	if _, errDrip := dripGenerated(ctx, w.Writer, sent, body, interval); errDrip != nil {
+		return errDrip
+	}
+
+	slog.InfoContext(ctx, "holding connection open after short response", "declared", declared, "sent", sent)
+
+	waitClosed(ctx, w.Reader)
+
+	return nil
+}
+
+// garbageBytes are written by slow-write-then-upgrade-to-garbage after the switchover.
+var garbageBytes = []byte("\x00\xff\xfeHTTP/1.1 \x1b[0m\r\n\x80\x7f")
+
+// slowWriteThenGarbage drips a valid response at 'rate' byte/s, but replaces the body
+// after 'switch-at' bytes with garbage of the same length, so the framing stays intact.
+func slowWriteThenGarbage(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	resp := limericResponse(req)
+	headerSize := bytes.Index(resp, []byte("\r\n\r\n")) + 4
+	header, body := resp[:headerSize], resp[headerSize:]
+
+	switchAt, errSwitch := queryInt(query, "switch-at", len(body)/2)
+	if errSwitch != nil {
+		badRequest(rw, errSwitch)
+		return nil
+	}
+	switchAt = min(switchAt, len(body))
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	if _, errWrite := w.Write(header); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	slog.InfoContext(ctx, "writing slow response", "switch_at", switchAt, "interval", interval)
+
+	if _, errDrip := drip(ctx, w.Writer, body[:switchAt], interval); errDrip != nil {
+		return errDrip
+	}
+
+	garbage := bytes.Repeat(garbageBytes, len(body)/len(garbageBytes)+1)[:len(body)-switchAt]
+	slog.InfoContext(ctx, "switching to garbage", "offset", switchAt, "garbage_bytes", len(garbage))
+
+	if _, errDrip := drip(ctx, w.Writer, garbage, interval); errDrip != nil {
+		return errDrip
+	}
+
+	return nil
+}
+
+// slowWritePeriodicFlushErrors writes the response byte by byte at 'rate' byte/s and,
+// every 'error-every' bytes, fails a write with an expired write deadline,
+// then clears the deadline after 'glitch' and writes the byte again.
+func slowWritePeriodicFlushErrors(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	errorEvery, errEvery := queryInt(query, "error-every", 20)
+	if errEvery == nil && errorEvery == 0 {
+		errEvery = errors.New("error-every must be positive")
+	}
+	if errEvery != nil {
+		badRequest(rw, errEvery)
+		return nil
+	}
+
+	glitch, errGlitch := queryDuration(query, "glitch", 500*time.Millisecond)
+	if errGlitch != nil {
+		badRequest(rw, errGlitch)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	// bufio.Writer errors are sticky, so bytes are written to the connection directly
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	slog.InfoContext(ctx, "writing slow response with flush errors", "interval", interval, "error_every", errorEvery, "glitch", glitch)
+
+	for i, b := range limericResponse(req) {
+		if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+			return errSleep
+		}
+
+		glitched := i > 0 && i%errorEvery == 0
+		if glitched {
+			_ = conn.SetWriteDeadline(time.Now())
+			_, errWrite := conn.Write([]byte{b})
+			slog.InfoContext(ctx, "simulated flush error", "offset", i, "error", errWrite)
+
+			if errSleep := sleepCtx(ctx, glitch); errSleep != nil {
+				return errSleep
+			}
+			_ = conn.SetWriteDeadline(time.Time{})
+
+			if errWrite == nil {
+				// the byte made it before the deadline was checked
+				continue
+			}
+		}
+
+		_, errWrite := conn.Write([]byte{b})
+		if glitched {
+			slog.InfoContext(ctx, "after flush error", "offset", i, "recovered", errWrite == nil)
+		}
+		if errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+	}
+
+	return nil
+}
+
+// parseAbortAt parses an abort offset as a byte count or a percentage of size, e.g. "100" or "50%".
+func parseAbortAt(s string, size int) (int, error) {
+	if percent, ok := strings.CutSuffix(s, "%"); ok {
+		value, err := strconv.Atoi(percent)
+		if err != nil || value < 0 || value > 100 {
+			return 0, fmt.Errorf("malformed abort-at percentage %q", s)
+		}
+		return size * value / 100, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("malformed abort-at %q", s)
+	}
+
+	return min(value, size), nil
+}
+
+// slowWriteCancellable writes the response byte by byte at 'rate' byte/s and closes
+// the connection at 'abort-at' offset, taken from X-Abort-At request header if the parameter is absent.
+func slowWriteCancellable(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	abortAtStr := query.Get("abort-at")
+	if abortAtStr == "" {
+		abortAtStr = req.Header.Get("X-Abort-At")
+	}
+	if abortAtStr == "" {
+		abortAtStr = "50%"
+	}
+
+	resp := limericResponse(req)
+
+	abortAt, errAbortAt := parseAbortAt(abortAtStr, len(resp))
+	if errAbortAt != nil {
+		badRequest(rw, errAbortAt)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	slog.InfoContext(ctx, "writing slow response to abort", "abort_at", abortAt, "total", len(resp), "interval", interval)
+
+	if _, errDrip := drip(ctx, w.Writer, resp[:abortAt], interval); errDrip != nil {
+		return errDrip
+	}
+
+	if abortAt < len(resp) {
+		slog.InfoContext(ctx, "aborting slow response", "offset", abortAt, "total", len(resp))
+	}
+
+	return nil
+}
+
+// slowWriteSegments writes the response in 'segment-size' chunks, each in its own TCP segment,
+// waiting 'interval' between them. Zero segment size enables Nagle's algorithm and
+// writes the response at once, letting the kernel coalesce it.
+func slowWriteSegments(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	segmentSize, errSegmentSize := queryInt(query, "segment-size", 1)
+	if errSegmentSize != nil {
+		badRequest(rw, errSegmentSize)
+		return nil
+	}
+
+	interval, errInterval := queryDuration(query, "interval", 10*time.Millisecond)
+	if errInterval != nil {
+		badRequest(rw, errInterval)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	// nothing is buffered right after hijacking, but be safe
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	tcp, isTCP := tcpConn(conn)
+	if isTCP {
+		_ = tcp.SetNoDelay(segmentSize > 0)
+	}
+
+	resp := limericResponse(req)
+
+	if segmentSize == 0 {
+		slog.InfoContext(ctx, "writing coalesced response", "nodelay", false, "tcp", isTCP)
+
+		if _, errWrite := conn.Write(resp); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		return nil
+	}
+
+	slog.InfoContext(ctx, "writing segmented response",
+		"segment_size", segmentSize, "interval", interval, "nodelay", true, "tcp", isTCP)
+
+	for len(resp) > 0 {
+		segment := resp[:min(segmentSize, len(resp))]
+		resp = resp[len(segment):]
+
+		if _, errWrite := conn.Write(segment); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+
+		if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+			return errSleep
+		}
+	}
+
+	return nil
+}
+
+// slowWriteBackpressure writes 'size' bytes of repeated limeric at 'rate' byte/s,
+// a portion each 100ms, reporting portions blocked by the client not reading fast enough.
+func slowWriteBackpressure(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	const tick = 100 * time.Millisecond
+
+	rate, errRate := queryInt(query, "rate", 16*1024)
+	if errRate == nil && rate < int(time.Second/tick) {
+		errRate = fmt.Errorf("rate must be at least %d byte/s", time.Second/tick)
+	}
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	size, errSize := queryInt(query, "size", 1024*1024)
+	if errSize != nil {
+		badRequest(rw, errSize)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	header := &bytes.Buffer{}
+	writeStrs(header,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Length: ", strconv.Itoa(size), "\r\n",
+		"Content-Type: text/plain\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	if _, errWrite := w.Write(header.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+	if errFlush := w.Flush(); errFlush != nil {
+		return fmt.Errorf("writing response: %w", errFlush)
+	}
+
+	perTick := rate / int(time.Second/tick)
+
+	// a tick's worth of bytes is written chunk by chunk, so the rate isn't limited by memory
+	chunk := make([]byte, min(perTick, generatedChunkSize))
+	for i := range chunk {
+		chunk[i] = limeric[i%len(limeric)]
+	}
+
+	slog.InfoContext(ctx, "writing response with backpressure report", "rate", rate, "size", size, "per_tick", perTick)
+
+	var (
+		writes, blocked    int
+		blockedTotal, peak time.Duration
+	)
+	for written := 0; written < size; {
+		tickEnd := written + min(perTick, size-written)
+
+		start := time.Now()
+		for written < tickEnd {
+			n, errWrite := conn.Write(chunk[:min(len(chunk), tickEnd-written)])
+			written += n
+
+			if errWrite != nil {
+				return fmt.Errorf("writing response: %w", errWrite)
+			}
+		}
+		latency := time.Since(start)
+		writes++
+
+		slog.DebugContext(ctx, "write latency", "offset", written, "latency", latency)
+
+		peak = max(peak, latency)
+		if latency >= tick {
+			blocked++
+			blockedTotal += latency
+			slog.InfoContext(ctx, "write blocked by client", "offset", written, "latency", latency)
+		}
+
+		if errSleep := sleepCtx(ctx, tick-latency); errSleep != nil {
+			return errSleep
+		}
+	}
+
+	slog.InfoContext(ctx, "backpressure report",
+		"writes", writes, "blocked_writes", blocked, "blocked_total", blockedTotal, "peak_latency", peak)
+
+	return nil
+}
+
+// slowWriteDeadline drips the response at 'rate' byte/s with a write deadline set 'deadline' ahead,
+// so the server's own write fails before the response is complete.
+func slowWriteDeadline(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	deadline, errDeadline := queryDuration(query, "deadline", 2*time.Second)
+	if errDeadline != nil {
+		badRequest(rw, errDeadline)
+		return nil
+	}
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	resp := limericResponse(req)
+
+	slog.InfoContext(ctx, "writing slow response with write deadline", "deadline", deadline, "interval", interval)
+
+	if errSet := conn.SetWriteDeadline(time.Now().Add(deadline)); errSet != nil {
+		return fmt.Errorf("setting write deadline: %w", errSet)
+	}
+
+	written, errDrip := drip(ctx, w.Writer, resp, interval)
+	switch {
+	case errors.Is(errDrip, os.ErrDeadlineExceeded):
+		slog.InfoContext(ctx, "write deadline fired", "written", written, "total", len(resp))
+		return nil
+	case errDrip != nil:
+		return errDrip
+	}
+
+	slog.InfoContext(ctx, "response written before write deadline", "written", written)
+
+	return nil
+}
+
+const utf8Limeric = `В царстве запросов, ответов и схем,
+HTTP статусом отказывает всем.
+404 — хмурый взгляд 😠,
+Клоуны в ряд 🤡,
+Ищут страницу, которой нет совсем.
+`
+
+// utf8Splits returns offsets to split utf8Limeric at according to the strategy.
+var utf8Splits = map[string]func(body string) []int{
+	// inside every multi-byte character, after its first byte
+	"mid-rune": func(body string) []int {
+		var splits []int
+		for offset, r := range body {
+			if utf8.RuneLen(r) > 1 {
+				splits = append(splits, offset+1)
+			}
+		}
+		return splits
+	},
+	// after every byte
+	"byte": func(body string) []int {
+		splits := make([]int, 0, len(body))
+		for offset := 1; offset < len(body); offset++ {
+			splits = append(splits, offset)
+		}
+		return splits
+	},
+	// between characters, as a well-behaved baseline
+	"rune": func(body string) []int {
+		var splits []int
+		for offset := range body {
+			if offset > 0 {
+				splits = append(splits, offset)
+			}
+		}
+		return splits
+	},
+}
+
+// slowWriteUTF8Split writes a multi-byte UTF-8 body in parts split according to 'split' strategy,
+// one part per 1/'rate' seconds.
+func slowWriteUTF8Split(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	strategy := query.Get("split")
+	if strategy == "" {
+		strategy = "mid-rune"
+	}
+
+	splitter, ok := utf8Splits[strategy]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown split %q, expected mid-rune, byte or rune", strategy))
+		return nil
+	}
+
+	interval, errRate := queryRate(query, 10)
+	if errRate != nil {
+		badRequest(rw, errRate)
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	header := &bytes.Buffer{}
+	writeStrs(header,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"Content-Length: ", strconv.Itoa(len(utf8Limeric)), "\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	if _, errWrite := w.Write(header.Bytes()); errWrite != nil {
+		return fmt.Errorf("writing response: %w", errWrite)
+	}
+
+	splits := splitter(utf8Limeric)
+
+	slog.InfoContext(ctx, "writing UTF-8 body in parts", "split", strategy, "parts", len(splits)+1, "interval", interval)
+
+	prev := 0
+	for _, offset := range append(splits, len(utf8Limeric)) {
+		if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+			return errSleep
+		}
+
+		if _, errWrite := w.WriteString(utf8Limeric[prev:offset]); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+
+		prev = offset
+	}
+
+	return nil
+}
+
+// rateRamps compute the rate at progress p in [0, 1] between start and end rates.
+var rateRamps = map[string]func(start, end, p float64) float64{
+	"linear": func(start, end, p float64) float64 {
+		return start + (end-start)*p
+	},
+	"exponential": func(start, end, p float64) float64 {
+		return start * math.Pow(end/start, p)
+	},
+}
+
+// throttleRamp writes the response byte by byte, changing the rate from 'start-rate' to 'end-rate' byte/s
+// over the response following the 'shape': linear or exponential.
+func throttleRamp(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	startRate, errStart := queryInt(query, "start-rate", 100)
+	if errStart == nil && startRate == 0 {
+		errStart = errors.New("start-rate must be positive")
+	}
+	if errStart != nil {
+		badRequest(rw, errStart)
+		return nil
+	}
+
+	endRate, errEnd := queryInt(query, "end-rate", 5)
+	if errEnd == nil && endRate == 0 {
+		errEnd = errors.New("end-rate must be positive")
+	}
+	if errEnd != nil {
+		badRequest(rw, errEnd)
+		return nil
+	}
+
+	shape := query.Get("shape")
+	if shape == "" {
+		shape = "linear"
+	}
+	ramp, ok := rateRamps[shape]
+	if !ok {
+		badRequest(rw, fmt.Errorf("unknown shape %q, expected linear or exponential", shape))
+		return nil
+	}
+
+	conn, w, errHijack := http.NewResponseController(rw).Hijack()
+	if errHijack != nil {
+		return fmt.Errorf("hijacking connection: %w", errHijack)
+	}
+
+	defer conn.Close()
+
+	resp := limericResponse(req)
+
+	slog.InfoContext(ctx, "writing response with rate ramp", "start_rate", startRate, "end_rate", endRate, "shape", shape)
+
+	for i, b := range resp {
+		progress := float64(i) / float64(len(resp)-1)
+		rate := ramp(float64(startRate), float64(endRate), progress)
+
+		if errSleep := sleepCtx(ctx, time.Duration(float64(time.Second)/rate)); errSleep != nil {
+			return errSleep
+		}
+
+		if errWrite := w.WriteByte(b); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := w.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+
+		slog.DebugContext(ctx, "ramp rate", "offset", i, "rate", rate)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type slowWriteState string
+
+const (
+	slowWriteRunning slowWriteState = "running"
+	slowWritePaused  slowWriteState = "paused"
+	slowWriteFast    slowWriteState = "fast"
+	slowWriteAborted slowWriteState = "aborted"
+)
+
+var slowWriteSignals = map[string]slowWriteState{
+	"pause":    slowWritePaused,
+	"resume":   slowWriteRunning,
+	"speed-up": slowWriteFast,
+	"abort":    slowWriteAborted,
+}
+
+// slowWriteControl is consulted by an in-flight slow-write on every byte.
+type slowWriteControl struct {
+	requestID, connID int64
+	started           time.Time
+	total             int
+
+	mu      sync.Mutex
+	state   slowWriteState
+	written int
+	// wake is closed and replaced on every state change
+	wake chan struct{}
+}
+
+type slowWriteStatus struct {
+	RequestID int64          `json:"request_id"`
+	ConnID    int64          `json:"conn_id"`
+	Started   time.Time      `json:"started"`
+	State     slowWriteState `json:"state"`
+	Written   int            `json:"written"`
+	Total     int            `json:"total"`
+}
+
+// wait blocks while the slow-write is paused and returns the state it should proceed with.
+func (c *slowWriteControl) wait(ctx context.Context) (slowWriteState, error) {
+	for {
+		c.mu.Lock()
+		state, wake := c.state, c.wake
+		c.mu.Unlock()
+
+		if state != slowWritePaused {
+			return state, nil
+		}
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (c *slowWriteControl) signal(state slowWriteState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == slowWriteAborted {
+		return
+	}
+
+	c.state = state
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+func (c *slowWriteControl) setWritten(n int) {
+	c.mu.Lock()
+	c.written = n
+	c.mu.Unlock()
+}
+
+func (c *slowWriteControl) status() slowWriteStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slowWriteStatus{
+		RequestID: c.requestID,
+		ConnID:    c.connID,
+		Started:   c.started,
+		State:     c.state,
+		Written:   c.written,
+		Total:     c.total,
+	}
+}
+
+// slowWriteRegistry tracks in-flight slow-writes by request ID.
+type slowWriteRegistry struct {
+	mu       sync.Mutex
+	controls map[int64]*slowWriteControl
+}
+
+// register adds a slow-write of total bytes to the registry.
+// The returned function must be called when the slow-write is finished.
+func (r *slowWriteRegistry) register(ctx context.Context, total int) (*slowWriteControl, func()) {
+	requestID, _ := RequestID(ctx)
+	connID, _ := ConnID(ctx)
+
+	control := &slowWriteControl{
+		requestID: requestID,
+		connID:    connID,
+		started:   time.Now(),
+		total:     total,
+		state:     slowWriteRunning,
+		wake:      make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.controls == nil {
+		r.controls = map[int64]*slowWriteControl{}
+	}
+	r.controls[requestID] = control
+
+	return control, func() {
+		r.mu.Lock()
+		delete(r.controls, requestID)
+		r.mu.Unlock()
+	}
+}
+
+// match returns in-flight slow-writes with given request and connection IDs, zero ID matches any.
+func (r *slowWriteRegistry) match(requestID, connID int64) []*slowWriteControl {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*slowWriteControl
+	for _, control := range r.controls {
+		if (requestID == 0 || control.requestID == requestID) &&
+			(connID == 0 || control.connID == connID) {
+			matched = append(matched, control)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].requestID < matched[j].requestID
+	})
+
+	return matched
+}
+
+// slowWriteSignal sends an optional 'signal' to in-flight slow-writes matching
+// 'request-id' and 'conn-id' and reports their state as JSON.
+func (srv *service) slowWriteSignal(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	requestID, errRequestID := queryInt(query, "request-id", 0)
+	if errRequestID != nil {
+		badRequest(rw, errRequestID)
+		return nil
+	}
+
+	connID, errConnID := queryInt(query, "conn-id", 0)
+	if errConnID != nil {
+		badRequest(rw, errConnID)
+		return nil
+	}
+
+	signal := query.Get("signal")
+	state, ok := slowWriteSignals[signal]
+	if signal != "" && !ok {
+		badRequest(rw, fmt.Errorf("unknown signal %q, expected pause, resume, speed-up or abort", signal))
+		return nil
+	}
+
+	statuses := []slowWriteStatus{}
+	for _, control := range srv.slowWrites.match(int64(requestID), int64(connID)) {
+		if signal != "" {
+			control.signal(state)
+			slog.InfoContext(ctx, "signalling slow write", "signal", signal,
+				"target_request_id", control.requestID, "target_conn_id", control.connID)
+		}
+		statuses = append(statuses, control.status())
+	}
+
+	return writeJSON(rw, statuses)
+}
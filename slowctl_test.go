@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func slowWriteStatuses(t *testing.T, url string) []slowWriteStatus {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	var statuses []slowWriteStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		t.Fatalf("decoding slow write statuses: %v", err)
+	}
+
+	return statuses
+}
+
+func TestSlowWritePausedClientGone(t *testing.T) {
+	ts := newTestServer(t, &service{})
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET /?action=slow-write HTTP/1.1\r\nHost: badserv\r\n\r\n")); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	signalURL := ts.URL + "/?action=slow-write-signal&signal=pause"
+	deadline := time.Now().Add(5 * time.Second)
+	for len(slowWriteStatuses(t, signalURL)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slow write never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.Close()
+
+	statusURL := ts.URL + "/?action=slow-write-signal"
+	deadline = time.Now().Add(5 * time.Second)
+	for statuses := slowWriteStatuses(t, statusURL); len(statuses) > 0; statuses = slowWriteStatuses(t, statusURL) {
+		if time.Now().After(deadline) {
+			t.Fatalf("paused slow write outlived the client: %+v", statuses)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
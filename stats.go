@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets is the number of histogram buckets, the i-th bucket counts latencies below 2^i µs.
+const latencyBuckets = 32
+
+// stats aggregates server-side request stats.
+type stats struct {
+	requests  atomic.Int64
+	openConns atomic.Int64
+	latencies [latencyBuckets]atomic.Int64
+}
+
+func (s *stats) observe(latency time.Duration) {
+	s.requests.Add(1)
+
+	bucket := 0
+	for us := latency.Microseconds(); us > 0 && bucket < latencyBuckets-1; us >>= 1 {
+		bucket++
+	}
+	s.latencies[bucket].Add(1)
+}
+
+// connState is used as http.Server.ConnState hook to track open connections.
+func (s *stats) connState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.openConns.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		s.openConns.Add(-1)
+	}
+}
+
+// report logs stats for each interval: requests per second, p99 latency and open connections.
+func (s *stats) report(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		prevRequests  int64
+		prevLatencies [latencyBuckets]int64
+	)
+	for range ticker.C {
+		requests := s.requests.Load()
+
+		var latencies [latencyBuckets]int64
+		for i := range latencies {
+			latencies[i] = s.latencies[i].Load()
+		}
+
+		delta := requests - prevRequests
+		p99 := time.Duration(0)
+		if delta > 0 {
+			threshold := (delta*99 + 99) / 100
+			var seen int64
+			for i := range latencies {
+				seen += latencies[i] - prevLatencies[i]
+				if seen >= threshold {
+					p99 = time.Duration(1<<i) * time.Microsecond
+					break
+				}
+			}
+		}
+
+		slog.Info("stats",
+			"rps", float64(delta)/interval.Seconds(),
+			"p99_latency_below", p99,
+			"open_conns", s.openConns.Load(),
+			"requests_total", requests,
+		)
+
+		prevRequests, prevLatencies = requests, latencies
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamNDJSON streams 'count' JSON objects each 'interval'.
+// If 'error-at' is set, an error object is sent instead of the object with that index and the stream ends.
+func streamNDJSON(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	count, errCount := queryInt(query, "count", 10)
+	if errCount != nil {
+		badRequest(rw, errCount)
+		return nil
+	}
+
+	interval, errInterval := queryDuration(query, "interval", 500*time.Millisecond)
+	if errInterval != nil {
+		badRequest(rw, errInterval)
+		return nil
+	}
+
+	errorAt, errErrorAt := queryInt(query, "error-at", count)
+	if errErrorAt != nil {
+		badRequest(rw, errErrorAt)
+		return nil
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.Header().Set("Connection", "close")
+
+	controller := http.NewResponseController(rw)
+	enc := json.NewEncoder(rw)
+	lines := strings.Split(strings.TrimSpace(limeric), "\n")
+
+	slog.InfoContext(ctx, "streaming ndjson", "count", count, "interval", interval, "error_at", errorAt)
+
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+				return errSleep
+			}
+		}
+
+		var obj any = map[string]any{
+			"seq":  i,
+			"line": lines[i%len(lines)],
+		}
+		if i == errorAt {
+			obj = map[string]any{
+				"error": map[string]any{
+					"code":    http.StatusInternalServerError,
+					"message": "stream failed",
+				},
+			}
+		}
+
+		if errEncode := enc.Encode(obj); errEncode != nil {
+			return fmt.Errorf("writing response: %w", errEncode)
+		}
+		_ = controller.Flush()
+
+		if i == errorAt {
+			slog.InfoContext(ctx, "sent ndjson error object, closing stream", "seq", i)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// slowWriteKeepalive streams limeric lines as server-sent events, one each 'delay'.
+// While waiting for the next line, an SSE comment is sent each 'keepalive-interval',
+// so clients with an idle-read timeout keep going, while ones with a total timeout fail.
+func slowWriteKeepalive(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	delay, errDelay := queryDuration(query, "delay", 5*time.Second)
+	if errDelay != nil {
+		badRequest(rw, errDelay)
+		return nil
+	}
+
+	keepalive, errKeepalive := queryDuration(query, "keepalive-interval", time.Second)
+	if errKeepalive == nil && keepalive == 0 {
+		errKeepalive = errors.New("keepalive-interval must be positive")
+	}
+	if errKeepalive != nil {
+		badRequest(rw, errKeepalive)
+		return nil
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+
+	controller := http.NewResponseController(rw)
+	write := func(s string) error {
+		if _, errWrite := io.WriteString(rw, s); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		return controller.Flush()
+	}
+
+	slog.InfoContext(ctx, "streaming with keepalives", "delay", delay, "keepalive_interval", keepalive)
+
+	ticker := time.NewTicker(keepalive)
+	defer ticker.Stop()
+
+	for _, line := range strings.Split(strings.TrimSpace(limeric), "\n") {
+		next := time.NewTimer(delay)
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				next.Stop()
+				return ctx.Err()
+			case <-ticker.C:
+				if err := write(": keepalive\n\n"); err != nil {
+					next.Stop()
+					return err
+				}
+				slog.InfoContext(ctx, "sent keepalive")
+			case <-next.C:
+				break wait
+			}
+		}
+
+		if err := write("data: " + line + "\n\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partialWriteFlushBoundaries writes the limeric split at 'splits' byte offsets,
+// flushing each part and waiting 'interval' in between.
+func partialWriteFlushBoundaries(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	interval, errInterval := queryDuration(query, "interval", 100*time.Millisecond)
+	if errInterval != nil {
+		badRequest(rw, errInterval)
+		return nil
+	}
+
+	body := []byte(limeric)
+	splits := []int{}
+	if query.Get("splits") != "" {
+		for _, field := range strings.Split(query.Get("splits"), ",") {
+			offset, errOffset := strconv.Atoi(field)
+			if errOffset != nil || offset <= 0 || offset >= len(body) {
+				badRequest(rw, fmt.Errorf("malformed split offset %q, expected 1..%d", field, len(body)-1))
+				return nil
+			}
+			splits = append(splits, offset)
+		}
+	} else {
+		for offset := 7; offset < len(body); offset += 13 {
+			splits = append(splits, offset)
+		}
+	}
+
+	slices.Sort(splits)
+	splits = slices.Compact(splits)
+
+	rw.Header().Set("Content-Type", "text/plain")
+	controller := http.NewResponseController(rw)
+
+	slog.InfoContext(ctx, "writing body split at offsets", "splits", splits, "interval", interval)
+
+	prev := 0
+	for _, offset := range append(splits, len(body)) {
+		if prev > 0 {
+			if errSleep := sleepCtx(ctx, interval); errSleep != nil {
+				return errSleep
+			}
+		}
+
+		if _, errWrite := rw.Write(body[prev:offset]); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		if errFlush := controller.Flush(); errFlush != nil {
+			return fmt.Errorf("writing response: %w", errFlush)
+		}
+
+		prev = offset
+	}
+
+	return nil
+}
+
+// delayWithHeartbeat delays the response by 'total', sending a heartbeat each 'heartbeat-interval' meanwhile.
+// The 'heartbeat' parameter selects heartbeats: whitespace before a plain text body or SSE comments.
+func delayWithHeartbeat(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	total, errTotal := queryDuration(query, "total", 10*time.Second)
+	if errTotal != nil {
+		badRequest(rw, errTotal)
+		return nil
+	}
+
+	interval, errInterval := queryDuration(query, "heartbeat-interval", time.Second)
+	if errInterval == nil && interval == 0 {
+		errInterval = errors.New("heartbeat-interval must be positive")
+	}
+	if errInterval != nil {
+		badRequest(rw, errInterval)
+		return nil
+	}
+
+	var heartbeat, body string
+	switch kind := query.Get("heartbeat"); kind {
+	case "", "whitespace":
+		rw.Header().Set("Content-Type", "text/plain")
+		heartbeat, body = " ", limeric
+	case "sse":
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		heartbeat = ": heartbeat\n\n"
+		body = "data: " + strings.ReplaceAll(strings.TrimSpace(limeric), "\n", "\ndata: ") + "\n\n"
+	default:
+		badRequest(rw, fmt.Errorf("unknown heartbeat %q, expected whitespace or sse", kind))
+		return nil
+	}
+
+	controller := http.NewResponseController(rw)
+	write := func(s string) error {
+		if _, errWrite := io.WriteString(rw, s); errWrite != nil {
+			return fmt.Errorf("writing response: %w", errWrite)
+		}
+		return controller.Flush()
+	}
+
+	slog.InfoContext(ctx, "delaying response with heartbeats", "total", total, "heartbeat_interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	done := time.NewTimer(total)
+	defer done.Stop()
+
+	for sent := 1; ; sent++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done.C:
+			slog.InfoContext(ctx, "writing response after heartbeats")
+			return write(body)
+		case <-ticker.C:
+			if err := write(heartbeat); err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "sent heartbeat", "n", sent)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is available to -template-file templates as dot.
+type templateData struct {
+	Method    string
+	Path      string
+	Host      string
+	Query     url.Values
+	Header    http.Header
+	RequestID int64
+	ConnID    int64
+}
+
+// renderTemplate executes the template file with request data.
+// Files are included with {{ include "name" }} relative to the template directory,
+// names escaping it are rejected.
+func renderTemplate(file string, data templateData) ([]byte, error) {
+	dir := filepath.Dir(file)
+
+	funcs := template.FuncMap{
+		"include": func(name string) (string, error) {
+			if !filepath.IsLocal(filepath.FromSlash(name)) || !insideDir(dir, name) {
+				return "", fmt.Errorf("include %q escapes template directory", name)
+			}
+
+			content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return "", fmt.Errorf("include %q: %w", name, err)
+			}
+
+			return string(content), nil
+		},
+	}
+
+	tmpl, errParse := template.New(filepath.Base(file)).Funcs(funcs).ParseFiles(file)
+	if errParse != nil {
+		return nil, fmt.Errorf("parsing template: %w", errParse)
+	}
+
+	buf := &bytes.Buffer{}
+	if errExec := tmpl.Execute(buf, data); errExec != nil {
+		return nil, fmt.Errorf("executing template: %w", errExec)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// respondFromTemplate renders -template-file with the request data and responds with it as 'content-type'.
+// The template is parsed on each request, so it can be edited without restarting the server.
+func (srv *service) respondFromTemplate(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	if srv.templateFile == "" {
+		http.Error(rw, "templates are disabled, set -template-file", http.StatusNotFound)
+		return nil
+	}
+
+	contentType := req.URL.Query().Get("content-type")
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	if _, _, err := mime.ParseMediaType(contentType); err != nil || strings.ContainsAny(contentType, "\r\n") {
+		badRequest(rw, fmt.Errorf("malformed content-type %q", contentType))
+		return nil
+	}
+
+	connID, _ := ConnID(ctx)
+	requestID, _ := RequestID(ctx)
+
+	body, errRender := renderTemplate(srv.templateFile, templateData{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Host:      req.Host,
+		Query:     req.URL.Query(),
+		Header:    req.Header,
+		RequestID: requestID,
+		ConnID:    connID,
+	})
+	if errRender != nil {
+		slog.ErrorContext(ctx, "rendering template", "template_file", srv.templateFile, "error", errRender)
+		http.Error(rw, "rendering template: "+errRender.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "responding from template", "template_file", srv.templateFile, "bytes", len(body))
+
+	rw.Header().Set("Content-Type", contentType)
+	_, err := rw.Write(body)
+	return err
+}
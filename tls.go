@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func parseTLSVersion(s string) (uint16, error) {
+	version, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected one of 1.0, 1.1, 1.2, 1.3", s)
+	}
+
+	return version, nil
+}
+
+// parseCipherSuites parses a comma-separated list of cipher suite names, as reported by tls.CipherSuiteName.
+// Insecure suites are allowed on purpose: they're useful to check that clients refuse them.
+func parseCipherSuites(s string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// hasHTTP2Cipher reports whether the suites include one of the ciphers HTTP/2 requires (RFC 7540, section 9.2.2).
+func hasHTTP2Cipher(ids []uint16) bool {
+	for _, id := range ids {
+		if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tlsVersionName(version uint16) string {
+	if version == 0 {
+		return "default"
+	}
+
+	return tls.VersionName(version)
+}
+
+func cipherSuiteNames(ids []uint16) []string {
+	if ids == nil {
+		return []string{"default"}
+	}
+
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, tls.CipherSuiteName(id))
+	}
+
+	return names
+}
+
+// parseClientAuth parses -tls-client-auth mode. Client certificates are verified
+// against the CA if it's configured, and accepted as is otherwise.
+func parseClientAuth(mode string, withCA bool) (tls.ClientAuthType, error) {
+	switch {
+	case mode == "request" && withCA:
+		return tls.VerifyClientCertIfGiven, nil
+	case mode == "request":
+		return tls.RequestClientCert, nil
+	case mode == "require" && withCA:
+		return tls.RequireAndVerifyClientCert, nil
+	case mode == "require":
+		return tls.RequireAnyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth mode %q, expected request or require", mode)
+	}
+}
+
+// loadCertPool reads PEM-encoded certificates from the file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", file)
+	}
+
+	return pool, nil
+}
+
+type clientCertInfo struct {
+	Presented         bool       `json:"presented"`
+	Note              string     `json:"note,omitempty"`
+	Subject           string     `json:"subject,omitempty"`
+	Issuer            string     `json:"issuer,omitempty"`
+	SerialNumber      string     `json:"serial_number,omitempty"`
+	NotBefore         *time.Time `json:"not_before,omitempty"`
+	NotAfter          *time.Time `json:"not_after,omitempty"`
+	DNSNames          []string   `json:"dns_names,omitempty"`
+	FingerprintSHA256 string     `json:"fingerprint_sha256,omitempty"`
+	Chain             []string   `json:"chain,omitempty"`
+	Verified          bool       `json:"verified"`
+}
+
+// reflectTLSClientCert describes the client certificate presented during TLS handshake as JSON.
+func reflectTLSClientCert(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	info := clientCertInfo{}
+	switch {
+	case req.TLS == nil:
+		info.Note = "connection is not TLS, set -tls-cert and -tls-key"
+	case len(req.TLS.PeerCertificates) == 0:
+		info.Note = "no client certificate presented, the server requests it only with -tls-client-auth or -tls-client-ca set"
+	default:
+		cert := req.TLS.PeerCertificates[0]
+		fingerprint := sha256.Sum256(cert.Raw)
+
+		info.Presented = true
+		info.Subject = cert.Subject.String()
+		info.Issuer = cert.Issuer.String()
+		info.SerialNumber = cert.SerialNumber.String()
+		info.NotBefore = &cert.NotBefore
+		info.NotAfter = &cert.NotAfter
+		info.DNSNames = cert.DNSNames
+		info.FingerprintSHA256 = hex.EncodeToString(fingerprint[:])
+		info.Verified = len(req.TLS.VerifiedChains) > 0
+
+		for _, intermediate := range req.TLS.PeerCertificates[1:] {
+			info.Chain = append(info.Chain, intermediate.Subject.String())
+		}
+	}
+
+	if info.Presented {
+		slog.InfoContext(ctx, "client certificate presented", "subject", info.Subject, "verified", info.Verified)
+	} else {
+		slog.InfoContext(ctx, "no client certificate", "note", info.Note)
+	}
+
+	return writeJSON(rw, info)
+}
+
+type coalescingInfo struct {
+	Proto     string `json:"proto"`
+	ALPN      string `json:"alpn,omitempty"`
+	SNI       string `json:"sni"`
+	Host      string `json:"host"`
+	ConnID    int64  `json:"conn_id"`
+	Coalesced bool   `json:"coalesced"`
+	Note      string `json:"note,omitempty"`
+}
+
+// connectionCoalescingBait reports the TLS SNI the connection was opened for
+// and the authority the request is sent to. A client coalescing connections across
+// hostnames covered by the same certificate sends requests with a different authority.
+func connectionCoalescingBait(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+
+	info := coalescingInfo{
+		Proto: req.Proto,
+		Host:  req.Host,
+	}
+	info.ConnID, _ = ConnID(ctx)
+
+	if req.TLS == nil {
+		info.Note = "connection is not TLS, set -tls-cert and -tls-key"
+	} else {
+		info.SNI = req.TLS.ServerName
+		info.ALPN = req.TLS.NegotiatedProtocol
+
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		info.Coalesced = !strings.EqualFold(host, info.SNI)
+		if info.SNI == "" {
+			info.Note = "client sent no SNI"
+		}
+	}
+
+	slog.InfoContext(ctx, "reporting connection authority",
+		"sni", info.SNI, "authority", info.Host, "proto", info.Proto, "coalesced", info.Coalesced)
+
+	return writeJSON(rw, info)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// limericAs renders the limeric in a format matching the media type.
+func limericAs(mediaType string) []byte {
+	lines := strings.Split(strings.TrimSpace(limeric), "\n")
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		data, _ := json.Marshal(map[string]any{
+			"title": "limeric",
+			"lines": lines,
+		})
+		return data
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		data, _ := xml.Marshal(struct {
+			XMLName xml.Name `xml:"limeric"`
+			Lines   []string `xml:"line"`
+		}{Lines: lines})
+		return append([]byte(xml.Header), data...)
+	default:
+		return []byte(limeric)
+	}
+}
+
+// truncateAtContentTypeBoundary declares a full body of 'content-type' format,
+// but sends only first 'offset' bytes of it and closes the connection.
+func truncateAtContentTypeBoundary(rw http.ResponseWriter, req *http.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	contentType := query.Get("content-type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	mediaType, _, errMediaType := mime.ParseMediaType(contentType)
+	if errMediaType != nil || strings.ContainsAny(contentType, "\r\n") {
+		badRequest(rw, fmt.Errorf("malformed content-type %q", contentType))
+		return nil
+	}
+
+	body := limericAs(mediaType)
+
+	offset, errOffset := queryInt(query, "offset", len(body)/2)
+	if errOffset != nil {
+		badRequest(rw, errOffset)
+		return nil
+	}
+	offset = min(offset, len(body))
+
+	resp := &bytes.Buffer{}
+	writeStrs(resp,
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Type: ", contentType, "\r\n",
+		"Content-Length: ", strconv.Itoa(len(body)), "\r\n",
+		"Connection: close\r\n\r\n",
+	)
+	resp.Write(body[:offset])
+
+	slog.InfoContext(ctx, "writing truncated body", "content_type", contentType, "offset", offset, "declared", len(body))
+
+	if err := writeRaw(rw, resp.Bytes()); err != nil {
+		return fmt.Errorf("truncated body: %w", err)
+	}
+
+	return nil
+}